@@ -0,0 +1,261 @@
+package db2struct
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigFile is the project file db2struct reads when it is run without a table
+const ConfigFile = "db2struct.yaml"
+
+// Config is a db2struct.yaml project file: the connection profiles, the tables to generate,
+// where to write them and the tag options
+type Config struct {
+	// Profile is the connection used when none is chosen, defaults to the only profile
+	Profile  string             `yaml:"profile"`
+	Profiles map[string]Profile `yaml:"profiles"`
+
+	// Tables are the names, globs (order_*) or /regexps/ to generate, every table when empty
+	Tables  []string `yaml:"tables"`
+	Exclude []string `yaml:"exclude"`
+
+	Package string `yaml:"package"`
+	// Output is the directory of the generated files, defaults to the current one
+	Output string `yaml:"output"`
+	// Split writes the model and the repositories to different directories, like -s
+	Split bool `yaml:"split"`
+	Jobs  int  `yaml:"jobs"`
+
+	Tags      ConfigTags `yaml:"tags"`
+	CreatedAt string     `yaml:"created_at"`
+	UpdatedAt string     `yaml:"updated_at"`
+
+	// Overrides are keyed by table name
+	Overrides map[string]TableOptions `yaml:"overrides"`
+	// Types map database types or table.column names to go types, see TypeMap
+	Types TypeMap `yaml:"types"`
+	// FallbackType is the go type of the unmapped columns, they are errors when it is empty
+	FallbackType string `yaml:"fallback_type"`
+}
+
+// ConfigTags are the tag and type options, json defaults to true and the others to false
+type ConfigTags struct {
+	JSON        *bool `yaml:"json"`
+	Gorm        bool  `yaml:"gorm"`
+	Guregu      bool  `yaml:"guregu"`
+	Unsigned    bool  `yaml:"unsigned"`
+	TinyintBool bool  `yaml:"tinyint_bool"`
+}
+
+// Profile is a connection to read the tables from, a ddl file or a migrations directory
+type Profile struct {
+	Driver   string `yaml:"driver"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Database string `yaml:"database"`
+	// Schema is the postgres schema, defaults to public, and SSLMode the postgres sslmode
+	Schema  string `yaml:"schema"`
+	SSLMode string `yaml:"sslmode"`
+
+	DSN          string `yaml:"dsn"`
+	Socket       string `yaml:"socket"`
+	TLS          string `yaml:"tls"`
+	TLSCA        string `yaml:"tls_ca"`
+	TLSCert      string `yaml:"tls_cert"`
+	TLSKey       string `yaml:"tls_key"`
+	Timeout      string `yaml:"timeout"`
+	ReadTimeout  string `yaml:"read_timeout"`
+	WriteTimeout string `yaml:"write_timeout"`
+
+	DDL        string `yaml:"ddl"`
+	Migrations string `yaml:"migrations"`
+}
+
+// LoadConfig reads a project file, the ${VAR} references of the profiles and output are replaced
+// by the environment so that passwords stay out of the file. Other $ are kept, $${VAR} is a literal ${VAR}
+func LoadConfig(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseConfig(content)
+	if err != nil {
+		return nil, err
+	}
+	for name, p := range c.Profiles {
+		p.expandEnv()
+		c.Profiles[name] = p
+	}
+	c.Output = expandEnv(c.Output)
+	return c, nil
+}
+
+// envReference matches ${VAR} and its escaped form $${VAR}
+var envReference = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${VAR} references of a value by the environment
+func expandEnv(value string) string {
+	return envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		return os.Getenv(ref[2 : len(ref)-1])
+	})
+}
+
+// expandEnv replaces the ${VAR} references of the string options
+func (p *Profile) expandEnv() {
+	for _, value := range []*string{&p.Host, &p.User, &p.Password, &p.Database, &p.Schema, &p.SSLMode,
+		&p.DSN, &p.Socket, &p.TLS, &p.TLSCA, &p.TLSCert, &p.TLSKey, &p.Timeout, &p.ReadTimeout, &p.WriteTimeout,
+		&p.DDL, &p.Migrations} {
+		*value = expandEnv(*value)
+	}
+}
+
+// ParseConfig parses the yaml of a project file, unknown keys are errors
+func ParseConfig(content []byte) (*Config, error) {
+	var c Config
+	if err := yaml.UnmarshalStrict(content, &c); err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	if len(c.Profiles) == 0 {
+		return nil, fmt.Errorf("invalid config: no profiles")
+	}
+	for name, p := range c.Profiles {
+		switch p.Driver {
+		case "", "mysql", "postgres", "sqlite":
+		default:
+			return nil, fmt.Errorf("invalid config: profile %s: unknown driver %s", name, p.Driver)
+		}
+	}
+	if c.Profile != "" {
+		if _, ok := c.Profiles[c.Profile]; !ok {
+			return nil, fmt.Errorf("invalid config: no profile %s", c.Profile)
+		}
+	}
+	if err := c.Types.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	if c.FallbackType != "" {
+		if err := ValidateType(c.FallbackType); err != nil {
+			return nil, fmt.Errorf("invalid config: fallback_type: %s", err)
+		}
+	}
+	return &c, nil
+}
+
+// Options returns the generator options of the file
+func (c *Config) Options() Options {
+	return Options{
+		PackageName:    c.Package,
+		JSONAnnotation: c.Tags.JSON == nil || *c.Tags.JSON,
+		GormAnnotation: c.Tags.Gorm,
+		GureguTypes:    c.Tags.Guregu,
+		UnsignedTypes:  c.Tags.Unsigned,
+		TinyintAsBool:  c.Tags.TinyintBool,
+		CreatedAtKey:   c.CreatedAt,
+		UpdatedAtKey:   c.UpdatedAt,
+		Output:         c.Output,
+		Tables:         c.Overrides,
+		Types:          c.Types,
+		FallbackType:   c.FallbackType,
+	}
+}
+
+// Source opens the named profile, the default one when name is empty
+func (c *Config) Source(name string) (SchemaSource, error) {
+	if name == "" {
+		name = c.Profile
+	}
+	if name == "" {
+		if len(c.Profiles) > 1 {
+			return nil, fmt.Errorf("%d profiles and no default one, choose one of %s", len(c.Profiles), c.profileNames())
+		}
+		for only := range c.Profiles {
+			name = only
+		}
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("no profile %s, choose one of %s", name, c.profileNames())
+	}
+	return p.Source()
+}
+
+// profileNames lists the profiles for the error messages
+func (c *Config) profileNames() string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprint(names)
+}
+
+// SelectTables lists the tables of the source and keeps the ones of the file
+func (c *Config) SelectTables(source SchemaSource) ([]string, error) {
+	names, err := source.Tables()
+	if err != nil {
+		return nil, err
+	}
+	return FilterTables(names, c.Tables, c.Exclude)
+}
+
+// Source returns the SchemaSource of the profile, the ports default to the ones of the driver
+func (p Profile) Source() (SchemaSource, error) {
+	switch {
+	case p.DDL != "":
+		return &DDLSource{File: p.DDL}, nil
+	case p.Migrations != "":
+		return &MigrationsSource{Dir: p.Migrations}, nil
+	case p.Driver == "sqlite":
+		return &SqliteSource{File: p.Database}, nil
+	case p.Driver == "postgres":
+		port := p.Port
+		if port == 0 {
+			port = 5432
+		}
+		return &PostgresSource{User: p.User, Password: p.Password, Host: p.Host, Port: port, Database: p.Database, Schema: p.Schema, SSLMode: p.SSLMode, DSN: p.DSN}, nil
+	}
+
+	port := p.Port
+	if port == 0 {
+		port = 3306
+	}
+	var timeouts [3]time.Duration
+	for i, value := range []string{p.Timeout, p.ReadTimeout, p.WriteTimeout} {
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %s: %s", value, err)
+		}
+		timeouts[i] = d
+	}
+	return &MysqlSource{
+		User:         p.User,
+		Password:     p.Password,
+		Host:         p.Host,
+		Port:         port,
+		Socket:       p.Socket,
+		Database:     p.Database,
+		DSN:          p.DSN,
+		TLS:          p.TLS,
+		TLSCA:        p.TLSCA,
+		TLSCert:      p.TLSCert,
+		TLSKey:       p.TLSKey,
+		Timeout:      timeouts[0],
+		ReadTimeout:  timeouts[1],
+		WriteTimeout: timeouts[2],
+	}, nil
+}
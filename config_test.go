@@ -0,0 +1,136 @@
+package db2struct
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testConfig = `
+profile: local
+profiles:
+  local:
+    host: localhost
+    user: root
+    password: p@ss
+    database: shop
+    timeout: 3s
+  reports:
+    driver: postgres
+    database: reports
+  schema:
+    migrations: tests/migrations
+tables: [users, "p*", "/^acc/"]
+exclude: [accounts]
+package: entity
+output: internal
+split: true
+fallback_type: "[]byte"
+tags:
+  json: false
+  gorm: true
+  unsigned: true
+created_at: created_at
+overrides:
+  users:
+    struct: Member
+    updated_at: modified_at
+types:
+  decimal: github.com/shopspring/decimal.Decimal
+  orders.meta: json.RawMessage
+`
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(testConfig))
+
+	Convey("Should read the tables and overrides", t, func() {
+		So(err, ShouldBeNil)
+		So(config.Tables, ShouldResemble, []string{"users", "p*", "/^acc/"})
+		So(config.Exclude, ShouldResemble, []string{"accounts"})
+		So(config.Split, ShouldBeTrue)
+		So(config.Overrides["users"], ShouldResemble, TableOptions{StructName: "Member", UpdatedAtKey: "modified_at"})
+	})
+
+	Convey("Should fill the generator options", t, func() {
+		opts := config.Options()
+		So(opts.PackageName, ShouldEqual, "entity")
+		So(opts.Output, ShouldEqual, "internal")
+		So(opts.JSONAnnotation, ShouldBeFalse)
+		So(opts.GormAnnotation, ShouldBeTrue)
+		So(opts.UnsignedTypes, ShouldBeTrue)
+		So(opts.TinyintAsBool, ShouldBeFalse)
+		So(opts.CreatedAtKey, ShouldEqual, "created_at")
+		So(opts.Tables["users"].StructName, ShouldEqual, "Member")
+		So(opts.FallbackType, ShouldEqual, "[]byte")
+		So(opts.Types, ShouldResemble, TypeMap{"decimal": "github.com/shopspring/decimal.Decimal", "orders.meta": "json.RawMessage"})
+	})
+
+	Convey("Should open the profiles", t, func() {
+		source, err := config.Source("")
+		So(err, ShouldBeNil)
+		mysqlSource := source.(*MysqlSource)
+		So(mysqlSource.Port, ShouldEqual, 3306)
+		So(mysqlSource.Password, ShouldEqual, "p@ss")
+		So(mysqlSource.Timeout, ShouldEqual, 3*time.Second)
+
+		source, err = config.Source("reports")
+		So(err, ShouldBeNil)
+		So(source.(*PostgresSource).Port, ShouldEqual, 5432)
+
+		_, err = config.Source("staging")
+		So(err.Error(), ShouldEqual, "no profile staging, choose one of [local reports schema]")
+	})
+
+	Convey("Should select the tables of the file", t, func() {
+		source, err := config.Source("schema")
+		So(err, ShouldBeNil)
+		tables, err := config.SelectTables(source)
+		So(err, ShouldBeNil)
+		So(tables, ShouldResemble, []string{"purchases", "users"})
+	})
+
+	Convey("Should reject invalid files", t, func() {
+		_, err := ParseConfig([]byte("profiles:\n  local:\n    hots: localhost\n"))
+		So(err, ShouldNotBeNil)
+		_, err = ParseConfig([]byte("tables: [users]\n"))
+		So(err.Error(), ShouldEqual, "invalid config: no profiles")
+		_, err = ParseConfig([]byte("profiles:\n  local:\n    driver: oracle\n"))
+		So(err.Error(), ShouldEqual, "invalid config: profile local: unknown driver oracle")
+		_, err = ParseConfig([]byte("profile: prod\nprofiles:\n  local:\n    database: shop\n"))
+		So(err.Error(), ShouldEqual, "invalid config: no profile prod")
+		_, err = ParseConfig([]byte("profiles:\n  local:\n    database: shop\ntypes:\n  decimal: decimal.Decimal\n"))
+		So(err.Error(), ShouldStartWith, "invalid config: type decimal: unknown package decimal")
+		_, err = ParseConfig([]byte("profiles:\n  local:\n    database: shop\nfallback_type: geo.Point\n"))
+		So(err.Error(), ShouldStartWith, "invalid config: fallback_type: unknown package geo")
+
+		config, err := ParseConfig([]byte("profiles:\n  a:\n    ddl: a.sql\n  b:\n    ddl: b.sql\n"))
+		So(err, ShouldBeNil)
+		_, err = config.Source("")
+		So(err.Error(), ShouldEqual, "2 profiles and no default one, choose one of [a b]")
+	})
+
+	Convey("Should expand the environment", t, func() {
+		os.Setenv("DB2STRUCT_TEST_PASSWORD", "secret")
+		defer os.Unsetenv("DB2STRUCT_TEST_PASSWORD")
+		file := os.TempDir() + "/db2struct_test.yaml"
+		f, err := os.Create(file)
+		So(err, ShouldBeNil)
+		defer os.Remove(file)
+		_, _ = f.WriteString("profiles:\n  local:\n    password: ${DB2STRUCT_TEST_PASSWORD}\n" +
+			"  staging:\n    password: pa$$word\n    dsn: app:$${DB2STRUCT_TEST_PASSWORD}@tcp(db)/shop\n" +
+			"tables: [\"/^tmp_.*$/\", \"$orders\"]\n")
+		_ = f.Close()
+
+		config, err := LoadConfig(file)
+		So(err, ShouldBeNil)
+		So(config.Profiles["local"].Password, ShouldEqual, "secret")
+
+		Convey("Should keep the other $", func() {
+			So(config.Profiles["staging"].Password, ShouldEqual, "pa$$word")
+			So(config.Profiles["staging"].DSN, ShouldEqual, "app:${DB2STRUCT_TEST_PASSWORD}@tcp(db)/shop")
+			So(config.Tables, ShouldResemble, []string{"/^tmp_.*$/", "$orders"})
+		})
+	})
+}
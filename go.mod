@@ -1,11 +1,22 @@
 module github.com/hlf513/db2struct
 
-go 1.12
+go 1.19
 
 require (
 	github.com/droundy/goopt v0.0.0-20170604162106-0b8effe182da
 	github.com/go-sql-driver/mysql v1.4.1
 	github.com/howeyc/gopass v0.0.0-20190910152052-7cb4b85ec19c
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/smartystreets/goconvey v0.0.0-20190731233626-505e41936337
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
+	github.com/gopherjs/gopherjs v0.0.0-20181017120253-0766667cb4d1 // indirect
+	github.com/jtolds/gls v4.20.0+incompatible // indirect
+	github.com/smartystreets/assertions v0.0.0-20180927180507-b2de0cb4f26d // indirect
+	golang.org/x/crypto v0.0.0-20190605123033-f99c8df09eb5 // indirect
+	golang.org/x/sys v0.0.0-20190606165138-5da285871e9c // indirect
 	google.golang.org/appengine v1.6.2 // indirect
 )
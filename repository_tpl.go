@@ -7,23 +7,59 @@ package db2struct
 // TableName    string
 func getRepositoryInterfaceTpl() string {
 	return `
-type {{.StructName}}Repository interface {
+{{if .View}}// {{.StructName}}Repository reads the {{.TableName}} view
+{{else if .Keyless}}// {{.StructName}}Repository has no by id methods, {{.TableName}} has no primary key
+{{end}}type {{.StructName}}Repository interface {
 	TableName() string
-	Create(data *model.{{.StructName}}) (int, error)
+{{if .View}}
+	FetchOne(where map[string]interface{}, fields string) (*model.{{.StructName}}, error)
+	FetchByWhere(where map[string]interface{}, fields string) ([]*model.{{.StructName}}, error)
+{{else if .CompositeKey}}	Create(data *model.{{.StructName}}) (model.{{.StructName}}Key, error)
 
-	FetchOneById(id int, fields string) (*model.{{.StructName}}, error)
+	FetchOneByKey(key model.{{.StructName}}Key, fields string) (*model.{{.StructName}}, error)
 	FetchOne(where map[string]interface{}, fields string) (*model.{{.StructName}}, error)
 	FetchByWhere(where map[string]interface{}, fields string) ([]*model.{{.StructName}}, error)
-	FetchByIds(ids []int, fields string) ([]*model.{{.StructName}}, error)
 
-	DeleteOneById(id int) error
+	DeleteOneByKey(key model.{{.StructName}}Key) error
 	DeleteByWhere(where map[string]interface{}) error
 
-	UpdateOneById(id int, set map[string]interface{}) error
+	UpdateOneByKey(key model.{{.StructName}}Key, set map[string]interface{}) error
 	UpdateByWhere(where, set map[string]interface{}) error
+{{else if .Keyless}}	Create(data *model.{{.StructName}}) error
+
+	FetchOne(where map[string]interface{}, fields string) (*model.{{.StructName}}, error)
+	FetchByWhere(where map[string]interface{}, fields string) ([]*model.{{.StructName}}, error)
+
+	DeleteByWhere(where map[string]interface{}) error
+{{else}}	Create(data *model.{{.StructName}}) ({{.PrimaryKeyType}}, error)
 
+	FetchOneById(id {{$.PrimaryKeyType}}, fields string) (*model.{{.StructName}}, error)
+	FetchOne(where map[string]interface{}, fields string) (*model.{{.StructName}}, error)
+	FetchByWhere(where map[string]interface{}, fields string) ([]*model.{{.StructName}}, error)
+	FetchByIds(ids []{{$.PrimaryKeyType}}, fields string) ([]*model.{{.StructName}}, error)
+
+	DeleteOneById(id {{$.PrimaryKeyType}}) error
+	DeleteByWhere(where map[string]interface{}) error
+
+	UpdateOneById(id {{$.PrimaryKeyType}}, set map[string]interface{}) error
+	UpdateByWhere(where, set map[string]interface{}) error
+{{end}}
 	CountByWhere(where map[string]interface{}) (int, error)
 	Search(where map[string]interface{}, field string, others ...map[string]interface{}) ([]*model.{{.StructName}}, error)
+{{if .View}}	Paginate(where map[string]interface{}, fields string, page, size int) ([]*model.{{.StructName}}, error)
+{{end}}{{range .UniqueKeys}}
+	FetchBy{{.Name}}({{range .Fields}}{{.Arg}} {{.Type}}, {{end}}fields string) (*model.{{$.StructName}}, error)
+	ExistsBy{{.Name}}({{range .Fields}}{{.Arg}} {{.Type}}, {{end}}) (bool, error)
+	UpdateBy{{.Name}}({{range .Fields}}{{.Arg}} {{.Type}}, {{end}}set map[string]interface{}) error
+	DeleteBy{{.Name}}({{range .Fields}}{{.Arg}} {{.Type}}, {{end}}) error
+{{end}}{{if .PrimaryKey}}{{if .Associations}}
+{{range .Associations}}	FetchOneByIdWith{{.Field}}(id {{$.PrimaryKeyType}}, fields string) (*model.{{$.StructName}}, error)
+{{end}}{{end}}{{range .ManyToMany}}
+	Add{{.Field}}(id {{$.PrimaryKeyType}}, {{.Arg}} ...*model.{{.Type}}) error
+	Remove{{.Field}}(id {{$.PrimaryKeyType}}, {{.Arg}} ...*model.{{.Type}}) error
+	Replace{{.Field}}(id {{$.PrimaryKeyType}}, {{.Arg}} ...*model.{{.Type}}) error
+	List{{.Field}}(id {{$.PrimaryKeyType}}) ([]*model.{{.Type}}, error)
+{{end}}{{end}}
 }
 `
 }
@@ -42,22 +78,67 @@ func New{{.StructName}}Repository(db *gorm.DB) repository.{{.StructName}}Reposit
 	return &{{.StructName | lcfirst }}{db}
 }
 
-func (a *{{.StructName|lcfirst}}) Create(data *model.{{.StructName}}) (int, error) {
+{{if .View}}{{else if .CompositeKey}}
+func (a *{{.StructName|lcfirst}}) Create(data *model.{{.StructName}}) (model.{{.StructName}}Key, error) {
+	data.{{.CreatedAtKey|goformat}} = time.Now()
+	data.{{.UpdatedAtKey|goformat}} = time.Now()
+	if err := a.db.Create(data).Error; err != nil {
+		return model.{{.StructName}}Key{}, err
+	}
+	return data.{{.KeyMethod}}(), nil
+}
+
+func (a *{{.StructName|lcfirst}}) FetchOneByKey(key model.{{.StructName}}Key, fields string) (*model.{{.StructName}}, error) {
+	var ret model.{{.StructName}}
+
+	err := a.db.Select(fields).Where(map[string]interface{}{ {{range .PrimaryKeys}}"{{.Column}}": key.{{.Field}}, {{end}} }).First(&ret).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func (a *{{.StructName|lcfirst}}) DeleteOneByKey(key model.{{.StructName}}Key) error {
+	if err := a.db.Where(map[string]interface{}{ {{range .PrimaryKeys}}"{{.Column}}": key.{{.Field}}, {{end}} }).Delete(model.{{.StructName}}{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *{{.StructName|lcfirst}}) UpdateOneByKey(key model.{{.StructName}}Key, set map[string]interface{}) error {
+	set["{{.UpdatedAtKey}}"] = time.Now()
+	if err := a.db.Model(model.{{.StructName}}{}).Where(map[string]interface{}{ {{range .PrimaryKeys}}"{{.Column}}": key.{{.Field}}, {{end}} }).Update(set).Error; err != nil {
+		return err
+	}
+	return nil
+}
+{{else if .Keyless}}
+func (a *{{.StructName|lcfirst}}) Create(data *model.{{.StructName}}) error {
+{{if .CreatedAtKey}}	data.{{.CreatedAtKey|goformat}} = time.Now()
+{{end}}{{if .UpdatedAtKey}}	data.{{.UpdatedAtKey|goformat}} = time.Now()
+{{end}}	return a.db.Create(data).Error
+}
+{{else}}
+func (a *{{.StructName|lcfirst}}) Create(data *model.{{.StructName}}) (id {{.PrimaryKeyType}}, err error) {
 	if a.db.NewRecord(data) {
 		data.{{.CreatedAtKey|goformat}} = time.Now()
 		data.{{.UpdatedAtKey|goformat}} = time.Now()
-		if err := a.db.Create(data).Error; err != nil {
-			return 0, err
+		if err = a.db.Create(data).Error; err != nil {
+			return id, err
 		}
 		return data.{{.PrimaryKey|goformat}}, nil
 	}
-	return 0, errors.New("this is not a new record")
+	return id, errors.New("this is not a new record")
 }
 
-func (a *{{.StructName|lcfirst}}) FetchOneById(id int, fields string) (*model.{{.StructName}}, error) {
+func (a *{{.StructName|lcfirst}}) FetchOneById(id {{$.PrimaryKeyType}}, fields string) (*model.{{.StructName}}, error) {
 	var ret model.{{.StructName}}
 
-	err := a.db.Select(fields).First(&ret, id).Error
+	err := a.db.Select(fields).Where("{{.PrimaryKey}} = ?", id).First(&ret).Error
 	if err == gorm.ErrRecordNotFound {
 		return nil, nil
 	}
@@ -67,7 +148,7 @@ func (a *{{.StructName|lcfirst}}) FetchOneById(id int, fields string) (*model.{{
 
 	return &ret, nil
 }
-
+{{end}}
 func (a *{{.StructName|lcfirst}}) FetchOne(where map[string]interface{}, fields string) (*model.{{.StructName}}, error) {
 	var ret model.{{.StructName}}
 
@@ -115,11 +196,11 @@ func (a *{{.StructName|lcfirst}}) FetchByWhere(where map[string]interface{}, fie
 
 	return ret, nil
 }
-
-func (a *{{.StructName|lcfirst}}) FetchByIds(ids []int, fields string) ([]*model.{{.StructName}}, error) {
+{{if .PrimaryKey}}
+func (a *{{.StructName|lcfirst}}) FetchByIds(ids []{{$.PrimaryKeyType}}, fields string) ([]*model.{{.StructName}}, error) {
 	var ret []*model.{{.StructName}}
 
-	err := a.db.Select(fields).Find(&ret, ids).Error
+	err := a.db.Select(fields).Where("{{.PrimaryKey}} IN (?)", ids).Find(&ret).Error
 	if err == gorm.ErrRecordNotFound {
 		return nil, nil
 	}
@@ -131,14 +212,14 @@ func (a *{{.StructName|lcfirst}}) FetchByIds(ids []int, fields string) ([]*model
 	return ret, nil
 }
 
-func (a *{{.StructName|lcfirst}}) DeleteOneById(id int) error {
+func (a *{{.StructName|lcfirst}}) DeleteOneById(id {{$.PrimaryKeyType}}) error {
 	d := model.{{.StructName}}{ {{.PrimaryKey|goformat}}: id}
 	if err := a.db.Delete(&d).Limit(1).Error; err != nil {
 		return err
 	}
 	return nil
 }
-
+{{end}}{{if not .View}}
 func (a *{{.StructName|lcfirst}}) DeleteByWhere(where map[string]interface{}) error {
 	q := a.db
 	for k, v := range where {
@@ -153,15 +234,15 @@ func (a *{{.StructName|lcfirst}}) DeleteByWhere(where map[string]interface{}) er
 	}
 	return nil
 }
-
-func (a *{{.StructName|lcfirst}}) UpdateOneById(id int, set map[string]interface{}) error {
+{{end}}{{if .PrimaryKey}}
+func (a *{{.StructName|lcfirst}}) UpdateOneById(id {{$.PrimaryKeyType}}, set map[string]interface{}) error {
 	set["{{.UpdatedAtKey}}"] = time.Now()
 	if err := a.db.Model(model.{{.StructName}}{ {{.PrimaryKey|goformat}}: id}).Update(set).Limit(1).Error; err != nil {
 		return err
 	}
 	return nil
 }
-
+{{end}}{{if not .Keyless}}
 func (a *{{.StructName|lcfirst}}) UpdateByWhere(where, set map[string]interface{}) error {
 	set["{{.UpdatedAtKey}}"] = time.Now()
 
@@ -175,7 +256,7 @@ func (a *{{.StructName|lcfirst}}) UpdateByWhere(where, set map[string]interface{
 	}
 	return nil
 }
-
+{{end}}
 func (a *{{.StructName|lcfirst}}) CountByWhere(where map[string]interface{}) (int, error) {
 	c := 0
 
@@ -241,6 +322,99 @@ func (a *{{.StructName|lcfirst}}) Search(where map[string]interface{}, field str
 
 	return ret, nil
 }
-`
+{{if .View}}
+func (a *{{.StructName|lcfirst}}) Paginate(where map[string]interface{}, fields string, page, size int) ([]*model.{{.StructName}}, error) {
+	var ret []*model.{{.StructName}}
+
+	q := a.db.Select(fields)
+	for k, v := range where {
+		if v != nil {
+			q = q.Where(k, v)
+		} else {
+			q = q.Where(k)
+		}
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if err := q.Offset((page - 1) * size).Limit(size).Find(&ret).Error; err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+{{end}}{{range .UniqueKeys}}
+func (a *{{$.StructName|lcfirst}}) FetchBy{{.Name}}({{range .Fields}}{{.Arg}} {{.Type}}, {{end}}fields string) (*model.{{$.StructName}}, error) {
+	var ret model.{{$.StructName}}
+
+	err := a.db.Select(fields).Where(map[string]interface{}{ {{range .Fields}}"{{.Column}}": {{.Arg}}, {{end}} }).First(&ret).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func (a *{{$.StructName|lcfirst}}) ExistsBy{{.Name}}({{range .Fields}}{{.Arg}} {{.Type}}, {{end}}) (bool, error) {
+	c := 0
+	if err := a.db.Model(model.{{$.StructName}}{}).Where(map[string]interface{}{ {{range .Fields}}"{{.Column}}": {{.Arg}}, {{end}} }).Count(&c).Error; err != nil {
+		return false, err
+	}
+	return c > 0, nil
+}
+
+func (a *{{$.StructName|lcfirst}}) UpdateBy{{.Name}}({{range .Fields}}{{.Arg}} {{.Type}}, {{end}}set map[string]interface{}) error {
+{{if $.UpdatedAtKey}}	set["{{$.UpdatedAtKey}}"] = time.Now()
+{{end}}	if err := a.db.Model(model.{{$.StructName}}{}).Where(map[string]interface{}{ {{range .Fields}}"{{.Column}}": {{.Arg}}, {{end}} }).Update(set).Limit(1).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *{{$.StructName|lcfirst}}) DeleteBy{{.Name}}({{range .Fields}}{{.Arg}} {{.Type}}, {{end}}) error {
+	if err := a.db.Where(map[string]interface{}{ {{range .Fields}}"{{.Column}}": {{.Arg}}, {{end}} }).Delete(model.{{$.StructName}}{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+{{end}}{{if .PrimaryKey}}{{range .Associations}}
+func (a *{{$.StructName|lcfirst}}) FetchOneByIdWith{{.Field}}(id {{$.PrimaryKeyType}}, fields string) (*model.{{$.StructName}}, error) {
+	var ret model.{{$.StructName}}
+
+	err := a.db.Select(fields).Preload("{{.Field}}").Where("{{$.PrimaryKey}} = ?", id).First(&ret).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+{{end}}{{range .ManyToMany}}
+func (a *{{$.StructName|lcfirst}}) Add{{.Field}}(id {{$.PrimaryKeyType}}, {{.Arg}} ...*model.{{.Type}}) error {
+	return a.db.Model(&model.{{$.StructName}}{ {{$.PrimaryKey|goformat}}: id}).Association("{{.Field}}").Append({{.Arg}}).Error
+}
+
+func (a *{{$.StructName|lcfirst}}) Remove{{.Field}}(id {{$.PrimaryKeyType}}, {{.Arg}} ...*model.{{.Type}}) error {
+	return a.db.Model(&model.{{$.StructName}}{ {{$.PrimaryKey|goformat}}: id}).Association("{{.Field}}").Delete({{.Arg}}).Error
+}
+
+func (a *{{$.StructName|lcfirst}}) Replace{{.Field}}(id {{$.PrimaryKeyType}}, {{.Arg}} ...*model.{{.Type}}) error {
+	return a.db.Model(&model.{{$.StructName}}{ {{$.PrimaryKey|goformat}}: id}).Association("{{.Field}}").Replace({{.Arg}}).Error
+}
+
+func (a *{{$.StructName|lcfirst}}) List{{.Field}}(id {{$.PrimaryKeyType}}) ([]*model.{{.Type}}, error) {
+	var ret []*model.{{.Type}}
+	if err := a.db.Model(&model.{{$.StructName}}{ {{$.PrimaryKey|goformat}}: id}).Association("{{.Field}}").Find(&ret).Error; err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+{{end}}{{end}}`
 	
 }
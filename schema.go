@@ -0,0 +1,400 @@
+package db2struct
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SchemaSource reads table definitions, implemented by the database, ddl file and migrations sources
+type SchemaSource interface {
+	// Table returns the table with its columns and indexes
+	Table(name string) (*Table, error)
+	// Tables returns the names of all tables, sorted
+	Tables() ([]string, error)
+}
+
+// bulkSource is implemented by the sources that read many tables with a few queries or a single parse,
+// the results follow the order of names
+type bulkSource interface {
+	tables(names []string) ([]*Table, []error)
+}
+
+// LoadTables reads the named tables, in one go when the source supports it and otherwise
+// with at most workers concurrent Table calls. Tables and errors follow the order of names.
+// The join tables among them are linked to the tables they relate, see ManyToMany
+func LoadTables(source SchemaSource, names []string, workers int) ([]*Table, []error) {
+	var tables []*Table
+	var errs []error
+	if bulk, ok := source.(bulkSource); ok && len(names) > 1 {
+		tables, errs = bulk.tables(names)
+	} else {
+		tables = make([]*Table, len(names))
+		errs = make([]error, len(names))
+		parallel(len(names), workers, func(i int) {
+			tables[i], errs[i] = source.Table(names[i])
+		})
+	}
+	linkManyToMany(tables)
+	return tables, errs
+}
+
+// linkManyToMany adds the relations of the join tables to the tables they reference,
+// the table referenced by the first foreign key of a join table owns the relation
+func linkManyToMany(tables []*Table) {
+	byName := make(map[string]*Table)
+	for _, table := range tables {
+		if table != nil {
+			byName[table.Name] = table
+		}
+	}
+	for _, join := range tables {
+		if join == nil || !join.JoinTable() {
+			continue
+		}
+		first, second := join.ForeignKeys[0], join.ForeignKeys[1]
+		if owner, ok := byName[first.RefTable]; ok {
+			owner.ManyToMany = append(owner.ManyToMany, &ManyToMany{JoinTable: join.Name, Key: first, Ref: second, Owner: true})
+		}
+		if other, ok := byName[second.RefTable]; ok && second.RefTable != first.RefTable {
+			other.ManyToMany = append(other.ManyToMany, &ManyToMany{JoinTable: join.Name, Key: second, Ref: first})
+		}
+	}
+}
+
+// parallel calls fn for 0..n-1 with at most workers goroutines
+func parallel(n int, workers int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// failTables returns err for each of the n tables
+func failTables(n int, err error) ([]*Table, []error) {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return make([]*Table, n), errs
+}
+
+// sharedPool is the connection pool of a database source, opened on first use
+// and shared by all the concurrent calls
+type sharedPool struct {
+	once sync.Once
+	db   *sql.DB
+	err  error
+}
+
+func (p *sharedPool) open(open func() (*sql.DB, error)) (*sql.DB, error) {
+	p.once.Do(func() {
+		p.db, p.err = open()
+	})
+	return p.db, p.err
+}
+
+func (p *sharedPool) close() error {
+	if p.db == nil {
+		return nil
+	}
+	return p.db.Close()
+}
+
+// Table describes a database table, it is what the generator renders
+type Table struct {
+	Name string
+	// Schema is the postgres schema of the tables outside public, the generated TableName qualifies the name with it
+	Schema  string
+	Comment string
+	// View is set for database views, which get read-only models and repositories
+	View bool
+	// Driver selects the type mapping of the columns: mysql (default), postgres or sqlite
+	Driver  string
+	Columns []*Column
+	Indexes []*Index
+	// ForeignKeys are the foreign keys of the table, ReferencedBy those of other tables referencing it
+	ForeignKeys  []*ForeignKey
+	ReferencedBy []*ForeignKey
+	// ManyToMany are the relations through join tables, set by LoadTables when the join table is loaded too
+	ManyToMany []*ManyToMany
+}
+
+// Column describes a table column using the INFORMATION_SCHEMA.COLUMNS vocabulary
+type Column struct {
+	Name string
+	// DataType is the type without length or attributes, e.g. int
+	DataType string
+	// ColumnType is the complete type, e.g. int(10) unsigned
+	ColumnType string
+	Nullable   bool
+	// Key is PRI, UNI, MUL or empty
+	Key string
+	// Default is nil when the column has no default or defaults to NULL, literals are unquoted
+	Default *string
+	// Extra holds auto_increment, on update CURRENT_TIMESTAMP and DEFAULT_GENERATED
+	// for expression defaults, like in mysql 8
+	Extra   string
+	Comment string
+}
+
+// Index describes a table index, the primary key included
+type Index struct {
+	Name    string
+	Primary bool
+	Unique  bool
+	// Kind is UNIQUE, FULLTEXT or SPATIAL for these index types
+	Kind    string
+	Columns []string
+	// Lengths are the prefix lengths of the columns, 0 for whole columns and nil without prefixes
+	Lengths []int
+}
+
+// Length returns the prefix length of the n-th column, 0 when the whole column is indexed
+func (i *Index) Length(n int) int {
+	if n < len(i.Lengths) {
+		return i.Lengths[n]
+	}
+	return 0
+}
+
+// Position returns the position of the column in the index, -1 when the index does not use the column
+func (i *Index) Position(column string) int {
+	for n, c := range i.Columns {
+		if strings.EqualFold(c, column) {
+			return n
+		}
+	}
+	return -1
+}
+
+// ForeignKey describes a foreign key of Table referencing RefTable
+type ForeignKey struct {
+	Name       string
+	Table      string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+}
+
+// ManyToMany relates a table to Ref.RefTable through JoinTable, Key is the foreign key
+// of the join table referencing the table
+type ManyToMany struct {
+	JoinTable string
+	Key       *ForeignKey
+	Ref       *ForeignKey
+	// Owner is set on the side referenced by the first foreign key, which gets the repository helpers
+	Owner bool
+}
+
+// QualifiedName returns the name of the table prefixed with its schema, if any
+func (t *Table) QualifiedName() string {
+	return qualifiedName(t.Schema, t.Name)
+}
+
+func qualifiedName(schema, name string) string {
+	if schema == "" {
+		return name
+	}
+	return schema + "." + name
+}
+
+// Column returns the column with the given name, nil if the table has no such column
+func (t *Table) Column(name string) *Column {
+	for _, c := range t.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// AutoIncrement reports whether the database assigns the column value
+func (c *Column) AutoIncrement() bool {
+	return strings.Contains(strings.ToLower(c.Extra), "auto_increment")
+}
+
+// DefaultGenerated reports whether Default is an expression such as CURRENT_TIMESTAMP rather than a value
+func (c *Column) DefaultGenerated() bool {
+	return strings.Contains(strings.ToUpper(c.Extra), "DEFAULT_GENERATED")
+}
+
+// OnUpdateTimestamp reports whether the database sets the column on every update
+func (c *Column) OnUpdateTimestamp() bool {
+	return strings.Contains(strings.ToLower(c.Extra), "on update")
+}
+
+// PrimaryKey returns the columns of the primary key in table order
+func (t *Table) PrimaryKey() []*Column {
+	var columns []*Column
+	for _, c := range t.Columns {
+		if c.Key == "PRI" {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// JoinTable reports whether the table only relates two other tables: it has two foreign keys whose columns
+// are the primary key or a unique index, and no other columns than an auto increment id and timestamps
+func (t *Table) JoinTable() bool {
+	if len(t.ForeignKeys) != 2 {
+		return false
+	}
+	columns := make(map[string]bool)
+	for _, fk := range t.ForeignKeys {
+		if fk.RefTable == t.Name {
+			return false
+		}
+		for _, c := range fk.Columns {
+			columns[strings.ToLower(c)] = true
+		}
+	}
+	for _, c := range t.Columns {
+		if !columns[strings.ToLower(c.Name)] && !c.AutoIncrement() && !isTimestampType(c.DataType) {
+			return false
+		}
+	}
+	for _, index := range t.Indexes {
+		if index.Unique && len(index.Columns) == len(columns) && index.coversAll(columns) {
+			return true
+		}
+	}
+	return false
+}
+
+// coversAll reports whether every index column is one of columns, given in lower case
+func (i *Index) coversAll(columns map[string]bool) bool {
+	for _, c := range i.Columns {
+		if !columns[strings.ToLower(c)] {
+			return false
+		}
+	}
+	return true
+}
+
+// addForeignKey adds the foreign key to the tables it belongs to or references
+func addForeignKey(tables map[string]*Table, fk *ForeignKey) {
+	if table, ok := tables[fk.Table]; ok {
+		table.ForeignKeys = append(table.ForeignKeys, fk)
+	}
+	if table, ok := tables[fk.RefTable]; ok {
+		table.ReferencedBy = append(table.ReferencedBy, fk)
+	}
+}
+
+// sqlDefault splits a default written as SQL, e.g. 'new', 0, CURRENT_TIMESTAMP or (uuid()),
+// into the COLUMN_DEFAULT value and whether it is an expression. TRUE and FALSE stay true and false,
+// which postgres needs for its boolean columns, only mysql stores them as 1 and 0
+func sqlDefault(text string) (*string, bool) {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "", strings.EqualFold(text, "null"):
+		return nil, false
+	case len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'':
+		value := strings.Replace(text[1:len(text)-1], "''", "'", -1)
+		return &value, false
+	case strings.EqualFold(text, "true"), strings.EqualFold(text, "false"):
+		value := strings.ToLower(text)
+		return &value, false
+	case isCurrentTimestamp(text):
+		value := "CURRENT_TIMESTAMP"
+		return &value, true
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil {
+		return &text, false
+	}
+	return &text, true
+}
+
+// isCurrentTimestamp reports whether a default expression is the current time, e.g. now() or current_timestamp(3)
+func isCurrentTimestamp(expr string) bool {
+	expr = strings.ToLower(strings.TrimSpace(expr))
+	if i := strings.Index(expr, "("); i > 0 && strings.HasSuffix(expr, ")") {
+		expr = expr[:i]
+	}
+	switch expr {
+	case "current_timestamp", "now", "localtimestamp", "localtime":
+		return true
+	}
+	return false
+}
+
+// FilterTables keeps the tables matching one of the include patterns (all tables when there are none)
+// and none of the exclude patterns. Patterns are globs like order_* or regular expressions like /^tmp_/
+func FilterTables(tables []string, include []string, exclude []string) ([]string, error) {
+	includes, err := compileTablePatterns(include)
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := compileTablePatterns(exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []string
+	for _, table := range tables {
+		if (len(includes) == 0 || matchTable(includes, table)) && !matchTable(excludes, table) {
+			filtered = append(filtered, table)
+		}
+	}
+	return filtered, nil
+}
+
+func compileTablePatterns(patterns []string) ([]func(string) bool, error) {
+	var matchers []func(string) bool
+	for _, pattern := range patterns {
+		pattern := strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid table pattern %s: %s", pattern, err)
+			}
+			matchers = append(matchers, re.MatchString)
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid table pattern %s: %s", pattern, err)
+		}
+		matchers = append(matchers, func(table string) bool {
+			ok, _ := path.Match(pattern, table)
+			return ok
+		})
+	}
+	return matchers, nil
+}
+
+func matchTable(matchers []func(string) bool, table string) bool {
+	for _, match := range matchers {
+		if match(table) {
+			return true
+		}
+	}
+	return false
+}
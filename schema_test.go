@@ -0,0 +1,145 @@
+package db2struct
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFilterTables(t *testing.T) {
+	tables := []string{"order_items", "orders", "tmp_orders", "users", "user_roles"}
+
+	Convey("Should keep every table without patterns", t, func() {
+		filtered, err := FilterTables(tables, nil, nil)
+		So(err, ShouldBeNil)
+		So(filtered, ShouldResemble, tables)
+	})
+
+	Convey("Should filter with globs and regular expressions", t, func() {
+		filtered, err := FilterTables(tables, []string{"order*", "/^user_/"}, []string{"*_items"})
+		So(err, ShouldBeNil)
+		So(filtered, ShouldResemble, []string{"orders", "user_roles"})
+
+		filtered, err = FilterTables(tables, nil, []string{"/^tmp_/", "users"})
+		So(err, ShouldBeNil)
+		So(filtered, ShouldResemble, []string{"order_items", "orders", "user_roles"})
+	})
+
+	Convey("Should reject invalid patterns", t, func() {
+		_, err := FilterTables(tables, []string{"/(/"}, nil)
+		So(err, ShouldNotBeNil)
+		_, err = FilterTables(tables, nil, []string{"[a-"})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+// countingSource builds tables from their names and records the highest number of concurrent calls
+type countingSource struct {
+	running, max int32
+}
+
+func (s *countingSource) Table(name string) (*Table, error) {
+	running := atomic.AddInt32(&s.running, 1)
+	defer atomic.AddInt32(&s.running, -1)
+	for {
+		max := atomic.LoadInt32(&s.max)
+		if running <= max || atomic.CompareAndSwapInt32(&s.max, max, running) {
+			break
+		}
+	}
+	if name == "missing" {
+		return nil, fmt.Errorf("table %s not found", name)
+	}
+	return &Table{Name: name}, nil
+}
+
+func (s *countingSource) Tables() ([]string, error) {
+	return nil, nil
+}
+
+func TestLoadTables(t *testing.T) {
+	Convey("Should read the tables with a bounded number of workers, in order", t, func() {
+		var names []string
+		for i := 0; i < 50; i++ {
+			names = append(names, fmt.Sprintf("t%02d", i))
+		}
+		names[7] = "missing"
+
+		source := &countingSource{}
+		tables, errs := LoadTables(source, names, 3)
+		So(source.max, ShouldBeLessThanOrEqualTo, 3)
+		So(len(tables), ShouldEqual, len(names))
+		for i, name := range names {
+			if name == "missing" {
+				So(errs[i], ShouldNotBeNil)
+				So(tables[i], ShouldBeNil)
+				continue
+			}
+			So(errs[i], ShouldBeNil)
+			So(tables[i].Name, ShouldEqual, name)
+		}
+	})
+
+	Convey("Should replay the migrations once for all the tables", t, func() {
+		tables, errs := LoadTables(&MigrationsSource{Dir: "tests/migrations"}, []string{"users", "missing", "purchases"}, 4)
+		So(errs[0], ShouldBeNil)
+		So(tables[0].Name, ShouldEqual, "users")
+		So(errs[1], ShouldNotBeNil)
+		So(tables[1], ShouldBeNil)
+		So(errs[2], ShouldBeNil)
+		So(tables[2].Column("amount"), ShouldNotBeNil)
+	})
+}
+
+const joinTablesDDL = `CREATE TABLE users (id INT AUTO_INCREMENT PRIMARY KEY, name VARCHAR(32));
+CREATE TABLE roles (id INT AUTO_INCREMENT PRIMARY KEY, name VARCHAR(32));
+CREATE TABLE user_roles (user_id INT NOT NULL, role_id INT NOT NULL, created_at DATETIME,
+  PRIMARY KEY (user_id, role_id), FOREIGN KEY (user_id) REFERENCES users (id), FOREIGN KEY (role_id) REFERENCES roles (id));
+CREATE TABLE orders (id INT AUTO_INCREMENT PRIMARY KEY, buyer_id INT, seller_id INT,
+  FOREIGN KEY (buyer_id) REFERENCES users (id), FOREIGN KEY (seller_id) REFERENCES users (id));
+CREATE TABLE grants (id INT AUTO_INCREMENT PRIMARY KEY, user_id INT, role_id INT, expires_at DATE,
+  UNIQUE KEY uk_user_role (user_id, role_id), FOREIGN KEY (user_id) REFERENCES users (id), FOREIGN KEY (role_id) REFERENCES roles (id));`
+
+func TestJoinTables(t *testing.T) {
+	schema := newDDLSchema()
+	err := schema.exec(joinTablesDDL)
+	tables, _ := schema.lookup([]string{"users", "roles", "user_roles", "orders", "grants"}, "in the test ddl")
+
+	Convey("Should only detect the tables relating two tables by a unique pair", t, func() {
+		So(err, ShouldBeNil)
+		So(tables[2].JoinTable(), ShouldBeTrue)
+		So(tables[3].JoinTable(), ShouldBeFalse)
+		So(tables[4].JoinTable(), ShouldBeFalse)
+	})
+
+	Convey("Should link the join table to both sides, the first foreign key owning the relation", t, func() {
+		linkManyToMany(tables)
+		So(len(tables[0].ManyToMany), ShouldEqual, 1)
+		So(tables[0].ManyToMany[0].JoinTable, ShouldEqual, "user_roles")
+		So(tables[0].ManyToMany[0].Owner, ShouldBeTrue)
+		So(tables[0].ManyToMany[0].Ref.RefTable, ShouldEqual, "roles")
+		So(len(tables[1].ManyToMany), ShouldEqual, 1)
+		So(tables[1].ManyToMany[0].Owner, ShouldBeFalse)
+		So(tables[1].ManyToMany[0].Ref.RefTable, ShouldEqual, "users")
+		So(tables[3].ManyToMany, ShouldBeNil)
+	})
+}
+
+func TestSQLDefault(t *testing.T) {
+	Convey("Should split literal and expression defaults", t, func() {
+		for text, expected := range map[string]string{"'it''s'": "it's", "-1.5": "-1.5", "TRUE": "true", "false": "false"} {
+			value, generated := sqlDefault(text)
+			So(*value, ShouldEqual, expected)
+			So(generated, ShouldBeFalse)
+		}
+		for text, expected := range map[string]string{"now()": "CURRENT_TIMESTAMP", "current_timestamp": "CURRENT_TIMESTAMP", "(uuid())": "(uuid())"} {
+			value, generated := sqlDefault(text)
+			So(*value, ShouldEqual, expected)
+			So(generated, ShouldBeTrue)
+		}
+		value, _ := sqlDefault("NULL")
+		So(value, ShouldBeNil)
+	})
+}
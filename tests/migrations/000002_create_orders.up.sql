@@ -0,0 +1,8 @@
+CREATE TABLE orders (
+  id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  user_id INT NOT NULL,
+  amount DECIMAL(10,2) NOT NULL,
+  legacy_flag TINYINT(1) DEFAULT NULL
+);
+CREATE INDEX idx_user ON orders (user_id);
+INSERT INTO users (name, email) VALUES ('admin', 'admin@example.com');
@@ -0,0 +1 @@
+RENAME TABLE purchases TO orders;
@@ -0,0 +1,7 @@
+ALTER TABLE users
+  ADD COLUMN nickname VARCHAR(32) NULL COMMENT 'display name' AFTER name,
+  CHANGE COLUMN name full_name VARCHAR(128) NOT NULL,
+  ADD UNIQUE KEY uk_email (email),
+  ADD created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE orders DROP COLUMN legacy_flag, MODIFY amount BIGINT UNSIGNED NOT NULL FIRST;
+RENAME TABLE orders TO purchases;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE accounts (id BIGINT PRIMARY KEY, balance DECIMAL(12,2) NOT NULL DEFAULT 0);
+-- +goose StatementEnd
+ALTER TABLE accounts ALTER COLUMN balance SET DEFAULT 10;
+
+-- +goose Down
+DROP TABLE accounts;
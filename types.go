@@ -0,0 +1,218 @@
+package db2struct
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// TypeMap maps database types (decimal), full column types (decimal(10,2)) or table.column names to go types.
+// Types of other packages are written with their import path, e.g. github.com/shopspring/decimal.Decimal,
+// the packages of knownImports can be used without it, e.g. json.RawMessage
+type TypeMap map[string]string
+
+// knownImports are the packages whose types can be mapped by package name
+var knownImports = map[string]string{
+	"big":  "math/big",
+	"json": "encoding/json",
+	"null": "gopkg.in/guregu/null.v3",
+	"pq":   "github.com/lib/pq",
+	"sql":  "database/sql",
+	"time": "time",
+}
+
+// ParseTypeMap reads the type=gotype pairs of the --type flags
+func ParseTypeMap(pairs []string) (TypeMap, error) {
+	m := make(TypeMap, len(pairs))
+	for _, pair := range pairs {
+		i := strings.Index(pair, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid type mapping %s, use type=gotype or table.column=gotype", pair)
+		}
+		m[strings.TrimSpace(pair[:i])] = strings.TrimSpace(pair[i+1:])
+	}
+	return m, m.Validate()
+}
+
+// Validate checks that every go type can be written and imported
+func (m TypeMap) Validate() error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := ValidateType(m[key]); err != nil {
+			return fmt.Errorf("type %s: %s", key, err)
+		}
+	}
+	return nil
+}
+
+// ValidateType checks that a go type, e.g. the fallback type, can be written and imported
+func ValidateType(value string) error {
+	_, _, err := goType(value)
+	return err
+}
+
+// lower returns the map with lower case keys, the lookups are case insensitive
+func (m TypeMap) lower() TypeMap {
+	lowered := make(TypeMap, len(m))
+	for key, value := range m {
+		lowered[strings.ToLower(key)] = value
+	}
+	return lowered
+}
+
+// lookup returns the mapped type of the column, table.column wins over the column type and this over the data type.
+// The data type is also looked up without length, sqlite keeps the declared type such as VARCHAR(255)
+func (m TypeMap) lookup(table string, column *Column) (string, bool) {
+	for _, key := range []string{table + "." + column.Name, column.ColumnType, column.DataType, baseType(column.DataType)} {
+		if key == "" {
+			continue
+		}
+		if value, ok := m[strings.ToLower(key)]; ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// goType splits a mapped type into the go type of the field and the import spec of its package,
+// e.g. *decimal.Decimal and "github.com/shopspring/decimal" for *github.com/shopspring/decimal.Decimal
+func goType(value string) (string, string, error) {
+	name := strings.TrimSpace(value)
+	prefix := ""
+	for strings.HasPrefix(name, "*") || strings.HasPrefix(name, "[]") {
+		n := 1
+		if name[0] == '[' {
+			n = 2
+		}
+		prefix, name = prefix+name[:n], name[n:]
+	}
+
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 {
+		if name == "" {
+			return "", "", fmt.Errorf("empty go type")
+		}
+		// builtin types such as string, []byte or interface{}
+		return prefix + name, "", nil
+	}
+	path, typeName := name[:dot], name[dot+1:]
+	if !isExportedIdentifier(typeName) {
+		return "", "", fmt.Errorf("invalid go type %s", value)
+	}
+
+	if !strings.Contains(path, "/") {
+		known, ok := knownImports[path]
+		if !ok {
+			return "", "", fmt.Errorf("unknown package %s in %s, write its import path, e.g. github.com/shopspring/decimal.Decimal", path, value)
+		}
+		return prefix + path + "." + typeName, `"` + known + `"`, nil
+	}
+
+	pkg := packageName(path)
+	spec := `"` + path + `"`
+	if pkg != path[strings.LastIndex(path, "/")+1:] {
+		spec = pkg + " " + spec
+	}
+	return prefix + pkg + "." + typeName, spec, nil
+}
+
+// packageName guesses the package name of an import path, e.g. null for gopkg.in/guregu/null.v3 and mux for
+// github.com/gorilla/mux/v2. The import is aliased when the name differs from the last element of the path
+func packageName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && isMajorVersion(name) {
+		name = elems[len(elems)-2]
+	}
+	if i := strings.Index(name, ".v"); i > 0 {
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.Replace(strings.Replace(name, "-", "", -1), ".", "", -1)
+}
+
+// isMajorVersion reports whether a path element is a module major version such as v2
+func isMajorVersion(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+	for _, r := range elem[1:] {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isExportedIdentifier(name string) bool {
+	for i, r := range name {
+		if i == 0 && !unicode.IsUpper(r) {
+			return false
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// baseType strips the length or precision of a type, e.g. varchar for VARCHAR(255) or decimal for decimal(10,2)
+func baseType(dataType string) string {
+	if i := strings.Index(dataType, "("); i >= 0 {
+		return strings.TrimSpace(dataType[:i])
+	}
+	return dataType
+}
+
+// UnmappedColumn is a column whose type has no go type, neither built in nor in the TypeMap
+type UnmappedColumn struct {
+	Table  string
+	Column string
+	Type   string
+}
+
+func (c UnmappedColumn) String() string {
+	return fmt.Sprintf("%s.%s (%s)", c.Table, c.Column, c.Type)
+}
+
+// UnmappedError is returned by Generate for the unmapped columns when Options.FallbackType is empty
+type UnmappedError struct {
+	Columns []UnmappedColumn
+}
+
+func (e *UnmappedError) Error() string {
+	columns := make([]string, len(e.Columns))
+	for i, column := range e.Columns {
+		columns[i] = column.String()
+	}
+	return "no go type for " + strings.Join(columns, ", ") + ", map them with --type or set a fallback type"
+}
+
+// unmappedColumn describes the column with its full column type when it is known
+func unmappedColumn(table *Table, column *Column) UnmappedColumn {
+	columnType := column.ColumnType
+	if columnType == "" {
+		columnType = column.DataType
+	}
+	return UnmappedColumn{Table: table.Name, Column: column.Name, Type: columnType}
+}
+
+// Unmapped lists the columns of the tables without go type, in strict mode (no Options.FallbackType)
+// they make Generate fail, otherwise they get the fallback type
+func (g *Generator) Unmapped(tables []*Table) []UnmappedColumn {
+	var unmapped []UnmappedColumn
+	for _, table := range tables {
+		for _, column := range table.Columns {
+			// invalid mappings are reported by Render
+			if valueType, _, err := g.columnGoType(table, column); err == nil && valueType == "" {
+				unmapped = append(unmapped, unmappedColumn(table, column))
+			}
+		}
+	}
+	return unmapped
+}
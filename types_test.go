@@ -0,0 +1,73 @@
+package db2struct
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGoType(t *testing.T) {
+	Convey("Should split the go type and its import", t, func() {
+		for value, expected := range map[string][2]string{
+			"github.com/shopspring/decimal.Decimal":  {"decimal.Decimal", `"github.com/shopspring/decimal"`},
+			"*github.com/shopspring/decimal.Decimal": {"*decimal.Decimal", `"github.com/shopspring/decimal"`},
+			"[]github.com/google/uuid.UUID":          {"[]uuid.UUID", `"github.com/google/uuid"`},
+			"gopkg.in/guregu/null.v3.String":         {"null.String", `null "gopkg.in/guregu/null.v3"`},
+			"github.com/jackc/pgtype/v2.Numeric":     {"pgtype.Numeric", `pgtype "github.com/jackc/pgtype/v2"`},
+			"encoding/json.RawMessage":               {"json.RawMessage", `"encoding/json"`},
+			"json.RawMessage":                        {"json.RawMessage", `"encoding/json"`},
+			"sql.NullString":                         {"sql.NullString", `"database/sql"`},
+			"[]byte":                                 {"[]byte", ""},
+			"interface{}":                            {"interface{}", ""},
+		} {
+			goType, spec, err := goType(value)
+			So(err, ShouldBeNil)
+			So([2]string{goType, spec}, ShouldResemble, expected)
+		}
+	})
+
+	Convey("Should reject types that cannot be imported", t, func() {
+		_, _, err := goType("decimal.Decimal")
+		So(err.Error(), ShouldEqual, "unknown package decimal in decimal.Decimal, write its import path, e.g. github.com/shopspring/decimal.Decimal")
+		_, _, err = goType("github.com/shopspring/decimal")
+		So(err.Error(), ShouldEqual, "invalid go type github.com/shopspring/decimal")
+		_, _, err = goType("*")
+		So(err.Error(), ShouldEqual, "empty go type")
+	})
+}
+
+func TestTypeMap(t *testing.T) {
+	Convey("Should parse the type flags", t, func() {
+		m, err := ParseTypeMap([]string{"decimal=github.com/shopspring/decimal.Decimal", "orders.meta = json.RawMessage"})
+		So(err, ShouldBeNil)
+		So(m, ShouldResemble, TypeMap{"decimal": "github.com/shopspring/decimal.Decimal", "orders.meta": "json.RawMessage"})
+
+		_, err = ParseTypeMap([]string{"decimal"})
+		So(err.Error(), ShouldEqual, "invalid type mapping decimal, use type=gotype or table.column=gotype")
+		_, err = ParseTypeMap([]string{"year=int", "geometry=geo.Point"})
+		So(err.Error(), ShouldStartWith, "type geometry: unknown package geo")
+	})
+
+	Convey("Should prefer the column over the column type and the data type", t, func() {
+		m := TypeMap{"Orders.Meta": "json.RawMessage", "tinyint(1)": "bool", "tinyint": "int8", "json": "[]byte"}.lower()
+		lookup := func(table string, column *Column) string {
+			value, _ := m.lookup(table, column)
+			return value
+		}
+		So(lookup("orders", &Column{Name: "meta", DataType: "json", ColumnType: "json"}), ShouldEqual, "json.RawMessage")
+		So(lookup("users", &Column{Name: "meta", DataType: "json", ColumnType: "json"}), ShouldEqual, "[]byte")
+		So(lookup("users", &Column{Name: "active", DataType: "tinyint", ColumnType: "tinyint(1)"}), ShouldEqual, "bool")
+		So(lookup("users", &Column{Name: "level", DataType: "tinyint", ColumnType: "tinyint(4)"}), ShouldEqual, "int8")
+		_, ok := m.lookup("users", &Column{Name: "id", DataType: "int"})
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Should look the data type up without length", t, func() {
+		m := TypeMap{"varchar": "github.com/google/uuid.UUID"}.lower()
+		value, ok := m.lookup("users", &Column{Name: "code", DataType: "VARCHAR(36)", ColumnType: "VARCHAR(36)"})
+		So(ok, ShouldBeTrue)
+		So(value, ShouldEqual, "github.com/google/uuid.UUID")
+		So(baseType("decimal(10, 2)"), ShouldEqual, "decimal")
+		So(baseType("UNSIGNED BIG INT"), ShouldEqual, "UNSIGNED BIG INT")
+	})
+}
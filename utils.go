@@ -6,8 +6,8 @@ import (
 	"go/format"
 	"html/template"
 	"io/ioutil"
-	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"unicode"
@@ -20,6 +20,11 @@ const (
 	sqlNullInt       = "sql.NullInt64"
 	golangInt        = "int"
 	golangInt64      = "int64"
+	golangUint8      = "uint8"
+	golangUint16     = "uint16"
+	golangUint32     = "uint32"
+	golangUint64     = "uint64"
+	golangUint64Ptr  = "*uint64"
 	gureguNullFloat  = "null.Float"
 	sqlNullFloat     = "sql.NullFloat64"
 	golangFloat      = "float"
@@ -29,6 +34,15 @@ const (
 	sqlNullString    = "sql.NullString"
 	gureguNullTime   = "null.Time"
 	golangTime       = "time.Time"
+	gureguNullBool   = "null.Bool"
+	sqlNullBool      = "sql.NullBool"
+	golangBool       = "bool"
+	golangJSON       = "json.RawMessage"
+	pqBoolArray      = "pq.BoolArray"
+	pqInt64Array     = "pq.Int64Array"
+	pqFloat64Array   = "pq.Float64Array"
+	pqByteaArray     = "pq.ByteaArray"
+	pqStringArray    = "pq.StringArray"
 )
 
 // commonInitialisms is a set of common initialisms.
@@ -84,198 +98,453 @@ var intToWordMap = []string{
 // Debug level logging
 var Debug = false
 
-// 写入不同目录的文件中(分层)
-func Generate(columnTypes map[string]map[string]string, tableName string, structName string, pkgName string, jsonAnnotation bool, gormAnnotation bool, gureguTypes bool, createdKey, updatedKey string) ([]byte, error) {
+// Options configures the code produced by a Generator
+type Options struct {
+	// PackageName of the model, defaults to model
+	PackageName    string
+	JSONAnnotation bool
+	GormAnnotation bool
+	GureguTypes    bool
+	// UnsignedTypes maps the mysql unsigned integers to uint8...uint64
+	UnsignedTypes bool
+	// TinyintAsBool maps the mysql tinyint(1) columns to bool. bit(1) stays []byte,
+	// the driver returns bits as bytes which database/sql cannot scan into a bool
+	TinyintAsBool bool
+	// CreatedAtKey and UpdatedAtKey default to the time columns whose name contains create/update
+	CreatedAtKey string
+	UpdatedAtKey string
+	// Output is the directory the files are written to, defaults to the current one
+	Output string
+	// Tables are the per table overrides, keyed by table name
+	Tables map[string]TableOptions
+	// Types override the go types of database types or table.column names, see TypeMap
+	Types TypeMap
+	// FallbackType is the go type of the unmapped columns, e.g. []byte or interface{}.
+	// When empty Generate returns an UnmappedError for them
+	FallbackType string
+}
+
+// TableOptions override the struct name and the created/updated columns of one table
+type TableOptions struct {
+	StructName   string `yaml:"struct"`
+	CreatedAtKey string `yaml:"created_at"`
+	UpdatedAtKey string `yaml:"updated_at"`
+}
+
+// Generator renders models and repositories from tables. It keeps no state between tables,
+// so one Generator can be used for many tables and from concurrent goroutines
+type Generator struct {
+	opts Options
+}
+
+// NewGenerator returns a Generator with the given options
+func NewGenerator(opts Options) *Generator {
+	if opts.PackageName == "" {
+		opts.PackageName = "model"
+	}
+	opts.Types = opts.Types.lower()
+	return &Generator{opts: opts}
+}
+
+// Generate 写入不同目录的文件中(分层)
+func Generate(table *Table, structName string, pkgName string, jsonAnnotation bool, gormAnnotation bool, gureguTypes bool, createdKey, updatedKey string) ([]byte, error) {
+	g := NewGenerator(Options{
+		PackageName:    pkgName,
+		JSONAnnotation: jsonAnnotation,
+		GormAnnotation: gormAnnotation,
+		GureguTypes:    gureguTypes,
+		CreatedAtKey:   createdKey,
+		UpdatedAtKey:   updatedKey,
+	})
+	return g.Generate(table, structName)
+}
+
+// GenerateOne Given a Table with its columns and a name structName,
+// attempts to generate a struct definition
+// 写入一个文件
+func GenerateOne(table *Table, structName string, pkgName string, jsonAnnotation bool, gormAnnotation bool, gureguTypes bool, createdKey, updatedKey string) ([]byte, error) {
+	g := NewGenerator(Options{
+		PackageName:    pkgName,
+		JSONAnnotation: jsonAnnotation,
+		GormAnnotation: gormAnnotation,
+		GureguTypes:    gureguTypes,
+		CreatedAtKey:   createdKey,
+		UpdatedAtKey:   updatedKey,
+	})
+	return g.GenerateOne(table, structName)
+}
+
+// File is a generated file, Path is slash separated and relative to Options.Output
+type File struct {
+	Path     string
+	Contents []byte
+}
+
+// GenerateError is the error of one table, Err is an *UnmappedError, a *TimeColumnsError, a *FormatError,
+// an invalid type mapping naming the column or a template error
+type GenerateError struct {
+	Table string
+	Err   error
+}
+
+func (e *GenerateError) Error() string {
+	return e.Table + ": " + e.Err.Error()
+}
+
+// TimeColumnsError is returned when the created or updated column of a repository is not found
+type TimeColumnsError struct {
+	CreatedAt bool
+	UpdatedAt bool
+}
+
+func (e *TimeColumnsError) Error() string {
+	var missing []string
+	if e.CreatedAt {
+		missing = append(missing, "created")
+	}
+	if e.UpdatedAt {
+		missing = append(missing, "updated")
+	}
+	return "no " + strings.Join(missing, " and ") + " time column found, set them with --create_at/--update_at or in the overrides"
+}
+
+// FormatError is returned when a generated file is not valid go, Source is the unformatted file
+type FormatError struct {
+	Path   string
+	Source string
+	Err    error
+}
+
+func (e *FormatError) Error() string {
+	return "error formatting " + e.Path + ": " + e.Err.Error()
+}
+
+// Generate 写入不同目录的文件中(分层), structName defaults to the table name.
+// Files are only written with gorm annotations, see Render for the files themselves
+func (g *Generator) Generate(table *Table, structName string) ([]byte, error) {
+	return g.generate(table, structName, true)
+}
+
+// GenerateOne 写入一个文件, structName defaults to the table name.
+// The file is only written with gorm annotations, see Render for the file itself
+func (g *Generator) GenerateOne(table *Table, structName string) ([]byte, error) {
+	return g.generate(table, structName, false)
+}
+
+func (g *Generator) generate(table *Table, structName string, split bool) ([]byte, error) {
+	files, err := g.Render(table, structName, split)
+	if err != nil {
+		return nil, err
+	}
+	if g.opts.GormAnnotation == true {
+		if err := g.WriteFiles(files); err != nil {
+			return nil, &GenerateError{Table: table.Name, Err: err}
+		}
+	}
+	return []byte("done"), nil
+}
+
+// Render returns the files of a table without writing them. With split the model goes to model/<table>_model.go
+// and, with gorm annotations, the repository to repository/<table>_repository.go and its mysql implementation
+// to repository/mysql/<table>_repository.go. Otherwise everything goes to <table>.go.
+// structName defaults to the overrides or the table name, errors are *GenerateError
+func (g *Generator) Render(table *Table, structName string, split bool) ([]File, error) {
+	files, err := g.render(table, structName, split)
+	if err != nil {
+		return nil, &GenerateError{Table: table.Name, Err: err}
+	}
+	return files, nil
+}
+
+func (g *Generator) render(table *Table, structName string, split bool) ([]File, error) {
+	tableName := table.Name
+	if structName == "" {
+		structName = g.structName(tableName)
+	}
+	s := &generation{}
 	var dbTypes string
-	dbTypes = generateMysqlTypes(columnTypes, 0, jsonAnnotation, gormAnnotation, gureguTypes)
+	dbTypes = g.generateMysqlTypes(table, s)
+	if s.err != nil {
+		return nil, s.err
+	}
+	if len(s.unmapped) > 0 && g.opts.FallbackType == "" {
+		return nil, &UnmappedError{Columns: s.unmapped}
+	}
 	// package
-	src := fmt.Sprintf("package %s", pkgName)
-	// import
-	src = fmt.Sprintf("%s\n%s", src, generateAllImport())
-	// type struct
+	src := fmt.Sprintf("package %s", g.opts.PackageName)
+	// import, the file has the model and its TableName method
+	src = fmt.Sprintf("%s\n%s", src, s.generateAllImport())
+	// type struct, documented by the table comment
+	if table.Comment != "" {
+		src = fmt.Sprintf("%s\n%s", src, docComment(structName+" "+table.Comment))
+	}
 	src = fmt.Sprintf("%s\ntype %s %s}",
 		src,
 		structName,
 		dbTypes)
-	if gormAnnotation == true {
-		// model
-		if _, err := os.Stat("./model"); os.IsNotExist(err) {
-			err := os.Mkdir("model", 0755)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
+	src += s.constructor(structName)
+	src += s.keyType(structName)
+	src += s.readOnly(structName, tableName)
+
+	// join tables are managed through the many2many helpers of the tables they relate,
+	// they get neither repository nor time columns
+	repository := g.opts.GormAnnotation == true && !table.JoinTable()
+	if repository {
+		if err := g.checkTimeColumns(tableName, s); err != nil {
+			return nil, err
 		}
-		formatted, err := format.Source([]byte(src))
-		if err != nil {
-			log.Fatalf("error formatting: %s, was formatting\n%s", err, src)
-		}
-		path := fmt.Sprintf("model/%s_model.go", tableName)
-		_ = ioutil.WriteFile(path, formatted, 0644)
-		// repository_interface
-		if _, err := os.Stat("./repository"); os.IsNotExist(err) {
-			err := os.Mkdir("repository", 0755)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-		}
-		src := fmt.Sprintf("package %s", "repository")
-		src = fmt.Sprintf("%s\n%s", src, repoInterfaceTpl(structName, createdKey, updatedKey, tableName))
-		formatted2, err := format.Source([]byte(src))
-		if err != nil {
-			log.Fatalf("error formatting: %s, was formatting\n%s", err, src)
-		}
-		path2 := fmt.Sprintf("repository/%s_repository.go", tableName)
-		_ = ioutil.WriteFile(path2, formatted2, 0644)
+	}
 
-		// repository
-		if _, err := os.Stat("./repository/mysql"); os.IsNotExist(err) {
-			err := os.Mkdir("repository/mysql", 0755)
+	// gorm derives the table of a model from the struct name, which misses the schema
+	qualified := g.opts.GormAnnotation == true && table.Schema != ""
+
+	if !split {
+		if repository || qualified {
+			// 把所有的写入到一个文件
+			methods, err := g.tpl(structName, tableName, s)
 			if err != nil {
-				log.Fatal(err.Error())
+				return nil, err
 			}
+			src = fmt.Sprintf("%s\n%s", src, methods)
 		}
-		src2 := fmt.Sprintf("package %s", "mysql")
-		src2 = fmt.Sprintf("%s\n%s", src2, generateImport())
-		src2 = fmt.Sprintf("%s\n%s", src2, repoTpl(structName, createdKey, updatedKey, tableName))
-		formatted3, err := format.Source([]byte(src2))
+		file, err := formatFile(tableName+".go", src)
 		if err != nil {
-			log.Fatalf("error formatting: %s, was formatting\n%s", err, src2)
+			return nil, err
 		}
-		path3 := fmt.Sprintf("repository/mysql/%s_repository.go", tableName)
-		_ = ioutil.WriteFile(path3, formatted3, 0644)
+		return []File{file}, nil
 	}
-	return []byte("done"), nil
-}
 
-// Generate Given a Column map with datatypes and a name structName,
-// attempts to generate a struct definition
-// 写入一个文件
-func GenerateOne(columnTypes map[string]map[string]string, tableName string, structName string, pkgName string, jsonAnnotation bool, gormAnnotation bool, gureguTypes bool, createdKey, updatedKey string) ([]byte, error) {
-	var dbTypes string
-	dbTypes = generateMysqlTypes(columnTypes, 0, jsonAnnotation, gormAnnotation, gureguTypes)
-	// package
-	src := fmt.Sprintf("package %s", pkgName)
-	// import
-	src = fmt.Sprintf("%s\n%s", src, generateImport())
-	// type struct
-	src = fmt.Sprintf("%s\ntype %s %s}",
-		src,
-		structName,
-		dbTypes)
-	if gormAnnotation == true {
-		// 把所有的写入到一个文件
-		src = fmt.Sprintf("%s\n%s", src, tpl(structName, createdKey, updatedKey, tableName))
-		fp, _ := os.Create(tableName + ".go")
-		formatted, err := format.Source([]byte(src))
+	if qualified {
+		methods, err := g.tpl(structName, tableName, s)
 		if err != nil {
-			err = fmt.Errorf("error formatting: %s, was formatting\n%s", err, src)
+			return nil, err
 		}
-		_, _ = fp.WriteString(string(formatted))
-		_ = fp.Close()
+		src = fmt.Sprintf("%s\n%s", src, methods)
 	}
-	return []byte("done"), nil
-}
 
-func repoTpl(structName, createdKey, updatedKey, tableName string) string {
-	t := template.New("fieldname example")
-	t = t.Funcs(template.FuncMap{"lcfirst": Lcfirst})
-	t = t.Funcs(template.FuncMap{"goformat": goFormat})
-	t, _ = t.Parse(getRepositoryTpl())
-	if createdKey == "" {
-		createdKey = createdAtKey
+	// model
+	model, err := formatFile(fmt.Sprintf("model/%s_model.go", tableName), src)
+	if err != nil {
+		return nil, err
 	}
-	if updatedKey == "" {
-		updatedKey = updatedATKey
+	files := []File{model}
+	if !repository {
+		return files, nil
 	}
 
-	if createdKey == "" || updatedKey == "" {
-		log.Fatal("未找到创建时间字段、更新时间字段，请指定--created_at --updated_at选项")
+	// repository_interface
+	src = fmt.Sprintf("package %s", "repository")
+	src = fmt.Sprintf("%s\n%s", src, s.generateInterfaceImport())
+	methods, err := g.repoInterfaceTpl(structName, tableName, s)
+	if err != nil {
+		return nil, err
+	}
+	src = fmt.Sprintf("%s\n%s", src, methods)
+	iface, err := formatFile(fmt.Sprintf("repository/%s_repository.go", tableName), src)
+	if err != nil {
+		return nil, err
 	}
 
-	var buf bytes.Buffer
-	var p = struct {
-		StructName   string
-		PrimaryKey   string
-		CreatedAtKey string
-		UpdatedAtKey string
-		TableName    string
-	}{
-		structName,
-		pk,
-		createdKey,
-		updatedKey,
-		tableName,
+	// repository
+	src = fmt.Sprintf("package %s", "mysql")
+	createdKey, updatedKey := g.timeColumns(tableName, s)
+	src = fmt.Sprintf("%s\n%s", src, s.generateImport(createdKey != "" || updatedKey != ""))
+	methods, err = g.repoTpl(structName, tableName, s)
+	if err != nil {
+		return nil, err
+	}
+	src = fmt.Sprintf("%s\n%s", src, methods)
+	impl, err := formatFile(fmt.Sprintf("repository/mysql/%s_repository.go", tableName), src)
+	if err != nil {
+		return nil, err
 	}
-	_ = t.Execute(&buf, p)
-	return buf.String()
+	return append(files, iface, impl), nil
 }
 
-func repoInterfaceTpl(structName, createdKey, updatedKey, tableName string) string {
-	t := template.New("fieldname example")
-	t = t.Funcs(template.FuncMap{"lcfirst": Lcfirst})
-	t = t.Funcs(template.FuncMap{"goformat": goFormat})
-	t, _ = t.Parse(getRepositoryInterfaceTpl())
+// formatFile gofmts a generated file
+func formatFile(path, src string) (File, error) {
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return File{}, &FormatError{Path: path, Source: src, Err: err}
+	}
+	return File{Path: path, Contents: formatted}, nil
+}
+
+// WriteFiles writes the files to the output directory, creating their directories
+func (g *Generator) WriteFiles(files []File) error {
+	for _, file := range files {
+		path := g.path(file.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, file.Contents, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenerateAll renders the tables with at most workers goroutines, using Generate when split is set
+// and GenerateOne otherwise. Struct names default to the overrides or the table names, errors follow the order of tables
+func (g *Generator) GenerateAll(tables []*Table, split bool, workers int) []error {
+	errs := make([]error, len(tables))
+	parallel(len(tables), workers, func(i int) {
+		_, errs[i] = g.generate(tables[i], "", split)
+	})
+	return errs
+}
+
+// RenderAll renders the tables with at most workers goroutines without writing them,
+// files and errors follow the order of tables
+func (g *Generator) RenderAll(tables []*Table, split bool, workers int) ([][]File, []error) {
+	files := make([][]File, len(tables))
+	errs := make([]error, len(tables))
+	parallel(len(tables), workers, func(i int) {
+		files[i], errs[i] = g.Render(tables[i], "", split)
+	})
+	return files, errs
+}
+
+// StructName   string
+// PrimaryKey   string
+// CreatedAtKey string
+// UpdatedAtKey string
+// TableName    string
+type tplParams struct {
+	StructName string
+	// PrimaryKey is the column of a single column primary key, PrimaryKeyType its go type
+	PrimaryKey     string
+	PrimaryKeyType string
+	// PrimaryKeys are the columns of a composite primary key, set with CompositeKey
+	PrimaryKeys  []tplField
+	CompositeKey bool
+	// KeyMethod is the model method returning the composite primary key
+	KeyMethod string
+	// Keyless tables get a repository without the by id methods, views a read-only one
+	Keyless      bool
+	View         bool
+	CreatedAtKey string
+	UpdatedAtKey string
+	TableName    string
+	UniqueKeys   []tplKey
+	Associations []tplAssociation
+	ManyToMany   []tplAssociation
+}
+
+// tplKey is a unique index, Name is the method suffix, e.g. UserIDAndSlug for FetchByUserIDAndSlug
+type tplKey struct {
+	Name   string
+	Fields []tplField
+}
+
+// tplAssociation is an association field, Field is the method suffix, e.g. User for FetchOneByIdWithUser.
+// Type and Arg are the struct and parameter names of the many2many helpers
+type tplAssociation struct {
+	Field string
+	Type  string
+	Arg   string
+}
+
+// tplField is a column used as a method parameter
+type tplField struct {
+	Column string
+	Field  string
+	Arg    string
+	Type   string
+}
+
+// structName returns the struct name of the table overrides, or the formatted table name
+func (g *Generator) structName(tableName string) string {
+	if name := g.opts.Tables[tableName].StructName; name != "" {
+		return name
+	}
+	return fmtFieldName(tableName)
+}
+
+// path places a generated file in the output directory
+func (g *Generator) path(name string) string {
+	return filepath.Join(g.opts.Output, filepath.FromSlash(name))
+}
+
+// timeColumns returns the created/updated columns, the table overrides win over the created/updated options
+// and these over the detected columns
+func (g *Generator) timeColumns(tableName string, s *generation) (string, string) {
+	override := g.opts.Tables[tableName]
+	createdKey := override.CreatedAtKey
+	if createdKey == "" {
+		createdKey = g.opts.CreatedAtKey
+	}
 	if createdKey == "" {
-		createdKey = createdAtKey
+		createdKey = s.createdAtKey
+	}
+	updatedKey := override.UpdatedAtKey
+	if updatedKey == "" {
+		updatedKey = g.opts.UpdatedAtKey
 	}
 	if updatedKey == "" {
-		updatedKey = updatedATKey
+		updatedKey = s.updatedAtKey
 	}
+	return createdKey, updatedKey
+}
 
-	if createdKey == "" || updatedKey == "" {
-		log.Fatal("未找到创建时间字段、更新时间字段，请指定--created_at --updated_at选项")
+// checkTimeColumns reports the created/updated columns the repository needs and which are not found,
+// the repository of a view never writes them and the one of a keyless table only sets the columns it has
+func (g *Generator) checkTimeColumns(tableName string, s *generation) error {
+	createdKey, updatedKey := g.timeColumns(tableName, s)
+	if s.view || len(s.primaryKey) == 0 || (createdKey != "" && updatedKey != "") {
+		return nil
 	}
+	return &TimeColumnsError{CreatedAt: createdKey == "", UpdatedAt: updatedKey == ""}
+}
 
-	var buf bytes.Buffer
-	var p = struct {
-		StructName   string
-		PrimaryKey   string
-		CreatedAtKey string
-		UpdatedAtKey string
-		TableName    string
-	}{
+// tplData fills the template parameters
+func (g *Generator) tplData(structName, tableName string, s *generation) tplParams {
+	createdKey, updatedKey := g.timeColumns(tableName, s)
+	return tplParams{
 		structName,
-		pk,
+		s.pk,
+		s.pkType(),
+		s.primaryKey,
+		len(s.primaryKey) > 1,
+		s.keyMethod(),
+		len(s.primaryKey) == 0,
+		s.view,
 		createdKey,
 		updatedKey,
-		tableName,
+		qualifiedName(s.schema, tableName),
+		s.uniqueKeys,
+		s.associations,
+		s.manyToMany,
 	}
-	_ = t.Execute(&buf, p)
-	return buf.String()
 }
 
-func tpl(structName, createdKey, updatedKey, tableName string) string {
-	t := template.New("fieldname example")
+// execTpl renders a template, name is used in the errors
+func execTpl(name, text string, p tplParams) (string, error) {
+	t := template.New(name)
 	t = t.Funcs(template.FuncMap{"lcfirst": Lcfirst})
 	t = t.Funcs(template.FuncMap{"goformat": goFormat})
-	t, _ = t.Parse(getTpl())
-	if createdKey == "" {
-		createdKey = createdAtKey
-	}
-	if updatedKey == "" {
-		updatedKey = updatedATKey
-	}
-
-	if createdKey == "" || updatedKey == "" {
-		log.Fatal("未找到创建时间字段、更新时间字段，请指定--created_at --updated_at选项")
+	t, err := t.Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing the %s template: %w", name, err)
 	}
 
 	var buf bytes.Buffer
-	var p = struct {
-		StructName   string
-		PrimaryKey   string
-		CreatedAtKey string
-		UpdatedAtKey string
-		TableName    string
-	}{
-		structName,
-		pk,
-		createdKey,
-		updatedKey,
-		tableName,
+	if err := t.Execute(&buf, p); err != nil {
+		return "", fmt.Errorf("rendering the %s template: %w", name, err)
 	}
-	_ = t.Execute(&buf, p)
-	return buf.String()
+	return buf.String(), nil
+}
+
+func (g *Generator) repoTpl(structName, tableName string, s *generation) (string, error) {
+	return execTpl("repository", getRepositoryTpl(), g.tplData(structName, tableName, s))
+}
+
+func (g *Generator) repoInterfaceTpl(structName, tableName string, s *generation) (string, error) {
+	return execTpl("repository interface", getRepositoryInterfaceTpl(), g.tplData(structName, tableName, s))
+}
+
+func (g *Generator) tpl(structName, tableName string, s *generation) (string, error) {
+	return execTpl("model", getTpl(), g.tplData(structName, tableName, s))
 }
 
 // fmtFieldName formats a string as a struct key
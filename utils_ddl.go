@@ -0,0 +1,1316 @@
+package db2struct
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DDLSource reads tables from the CREATE TABLE statements of a ddl file (mysqldump --no-data)
+type DDLSource struct {
+	File string
+}
+
+// Table implements SchemaSource
+func (s *DDLSource) Table(name string) (*Table, error) {
+	return GetColumnsFromMysqlDDL(s.File, name)
+}
+
+// Tables implements SchemaSource
+func (s *DDLSource) Tables() ([]string, error) {
+	schema, err := parseDDLFile(s.File)
+	if err != nil {
+		return nil, err
+	}
+	return schema.tableNames(), nil
+}
+
+// tables parses the file once for all the tables
+func (s *DDLSource) tables(names []string) ([]*Table, []error) {
+	schema, err := parseDDLFile(s.File)
+	if err != nil {
+		return failTables(len(names), err)
+	}
+	return schema.lookup(names, "in "+s.File)
+}
+
+func parseDDLFile(ddlFile string) (*ddlSchema, error) {
+	content, err := ioutil.ReadFile(ddlFile)
+	if err != nil {
+		return nil, err
+	}
+	schema := newDDLSchema()
+	if err := schema.exec(string(content)); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// GetColumnsFromMysqlDDL Parse the CREATE TABLE statements of a ddl file (mysqldump --no-data) and return the table
+func GetColumnsFromMysqlDDL(ddlFile string, mysqlTable string) (*Table, error) {
+	content, err := ioutil.ReadFile(ddlFile)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := newDDLSchema()
+	if err := schema.exec(string(content)); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ddlFile, err)
+	}
+
+	tables, errs := schema.lookup([]string{mysqlTable}, "in "+ddlFile)
+	return tables[0], errs[0]
+}
+
+// ddlSchema is the in-memory state built from the CREATE/ALTER/DROP TABLE statements of a script
+type ddlSchema struct {
+	tables map[string]*ddlTable
+}
+
+type ddlTable struct {
+	name        string
+	comment     string
+	columns     []*ddlColumn
+	primaryKey  []string
+	indexes     []*ddlIndex
+	foreignKeys []*ddlForeignKey
+}
+
+type ddlColumn struct {
+	name       string
+	dataType   string
+	columnType string
+	nullable   bool
+	// nil when the column has no default or DEFAULT NULL, like COLUMN_DEFAULT
+	defaultValue *string
+	// defaultGenerated is set for expression defaults such as CURRENT_TIMESTAMP or (uuid())
+	defaultGenerated bool
+	extra            string
+	comment          string
+}
+
+type ddlIndex struct {
+	name    string
+	unique  bool
+	kind    string
+	columns []string
+	// lengths are the prefix lengths of the columns, 0 for whole columns and nil without prefixes
+	lengths []int
+}
+
+type ddlForeignKey struct {
+	name       string
+	columns    []string
+	refTable   string
+	refColumns []string
+}
+
+func newDDLSchema() *ddlSchema {
+	return &ddlSchema{tables: make(map[string]*ddlTable)}
+}
+
+// exec parses the sql script and applies every statement it understands,
+// statements other than CREATE/ALTER/DROP/RENAME TABLE and CREATE/DROP INDEX are ignored
+func (s *ddlSchema) exec(script string) error {
+	tokens, err := lexDDL(script)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range splitDDLStatements(tokens) {
+		p := &ddlParser{tokens: stmt}
+		if err := s.apply(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableNames returns the names of the tables, sorted
+func (s *ddlSchema) tableNames() []string {
+	var names []string
+	for _, table := range s.tables {
+		names = append(names, table.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookup returns the named tables, where tells where a missing table was searched
+func (s *ddlSchema) lookup(names []string, where string) ([]*Table, []error) {
+	tables := make([]*Table, len(names))
+	errs := make([]error, len(names))
+	for i, name := range names {
+		table, ok := s.tables[strings.ToLower(name)]
+		if !ok {
+			errs[i] = fmt.Errorf("table %s not found %s", name, where)
+			continue
+		}
+		tables[i] = table.table()
+		tables[i].ReferencedBy = s.referencedBy(table.name)
+	}
+	return tables, errs
+}
+
+// referencedBy returns the foreign keys of all tables referencing the table, sorted by table name
+func (s *ddlSchema) referencedBy(name string) []*ForeignKey {
+	var fks []*ForeignKey
+	for _, table := range s.tableNames() {
+		t := s.tables[strings.ToLower(table)]
+		for _, fk := range t.foreignKeys {
+			if strings.EqualFold(fk.refTable, name) {
+				fks = append(fks, fk.foreignKey(t.name))
+			}
+		}
+	}
+	return fks
+}
+
+func (s *ddlSchema) apply(p *ddlParser) error {
+	switch {
+	case p.isKeyword("CREATE"):
+		p.next()
+		p.acceptKeyword("TEMPORARY")
+		if p.isKeyword("UNIQUE") || p.isKeyword("FULLTEXT") || p.isKeyword("SPATIAL") || p.isKeyword("INDEX") {
+			return s.createIndex(p)
+		}
+		if !p.acceptKeyword("TABLE") {
+			return nil
+		}
+		table, err := p.parseCreateTable()
+		if err != nil {
+			return err
+		}
+		if table != nil {
+			s.tables[strings.ToLower(table.name)] = table
+		}
+	case p.isKeyword("DROP"):
+		p.next()
+		p.acceptKeyword("TEMPORARY")
+		if p.acceptKeyword("INDEX") {
+			name, err := p.parseIdentifier()
+			if err != nil {
+				return err
+			}
+			if !p.acceptKeyword("ON") {
+				return nil
+			}
+			table, err := s.table(p)
+			if err != nil {
+				return err
+			}
+			table.dropIndex(name)
+			return nil
+		}
+		if !p.acceptKeyword("TABLE") {
+			return nil
+		}
+		p.acceptKeywords("IF", "EXISTS")
+		for {
+			name, err := p.parseTableName()
+			if err != nil {
+				return err
+			}
+			delete(s.tables, strings.ToLower(name))
+			if !p.accept(",") {
+				break
+			}
+		}
+	case p.isKeyword("RENAME"):
+		p.next()
+		if !p.acceptKeyword("TABLE") {
+			return nil
+		}
+		for {
+			table, err := s.table(p)
+			if err != nil {
+				return err
+			}
+			if !p.acceptKeyword("TO") {
+				return p.errorf("expected TO")
+			}
+			if err := s.renameTable(p, table); err != nil {
+				return err
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+	case p.isKeyword("ALTER"):
+		p.next()
+		p.acceptKeyword("ONLINE")
+		p.acceptKeyword("IGNORE")
+		if !p.acceptKeyword("TABLE") {
+			return nil
+		}
+		table, err := s.table(p)
+		if err != nil {
+			return err
+		}
+		for !p.eof() {
+			if err := s.alterTable(p, table); err != nil {
+				return fmt.Errorf("alter table %s: %s", table.name, err)
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// table reads a table name and returns the table from the schema
+func (s *ddlSchema) table(p *ddlParser) (*ddlTable, error) {
+	name, err := p.parseTableName()
+	if err != nil {
+		return nil, err
+	}
+	table, ok := s.tables[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("table %s does not exist", name)
+	}
+	return table, nil
+}
+
+func (s *ddlSchema) renameTable(p *ddlParser, table *ddlTable) error {
+	name, err := p.parseTableName()
+	if err != nil {
+		return err
+	}
+	delete(s.tables, strings.ToLower(table.name))
+	table.name = name
+	s.tables[strings.ToLower(name)] = table
+	return nil
+}
+
+// createIndex applies CREATE [UNIQUE|FULLTEXT|SPATIAL] INDEX name ON table (columns)
+func (s *ddlSchema) createIndex(p *ddlParser) error {
+	index := &ddlIndex{}
+	switch kind := strings.ToUpper(p.next().text); kind {
+	case "UNIQUE":
+		index.unique = true
+		index.kind = kind
+		p.acceptKeyword("INDEX")
+	case "FULLTEXT", "SPATIAL":
+		index.kind = kind
+		p.acceptKeyword("INDEX")
+	}
+	index.name = p.parseIndexName()
+	if !p.acceptKeyword("ON") {
+		return p.errorf("expected ON")
+	}
+	table, err := s.table(p)
+	if err != nil {
+		return err
+	}
+	if index.columns, index.lengths, err = p.parseIndexKeyParts(); err != nil {
+		return err
+	}
+	table.indexes = append(table.indexes, index)
+	return nil
+}
+
+// alterTable applies one alter specification of ALTER TABLE
+func (s *ddlSchema) alterTable(p *ddlParser, table *ddlTable) error {
+	switch {
+	case p.acceptKeyword("ADD"):
+		p.acceptKeyword("COLUMN")
+		if !p.isSymbol("(") {
+			return table.addDefinition(p)
+		}
+		// ADD [COLUMN] (column, column...)
+		p.next()
+		for {
+			if err := table.addDefinition(p); err != nil {
+				return err
+			}
+			if !p.accept(",") {
+				return p.expect(")")
+			}
+		}
+	case p.acceptKeyword("DROP"):
+		switch {
+		case p.acceptKeywords("PRIMARY", "KEY"):
+			table.primaryKey = nil
+		case p.acceptKeyword("INDEX"), p.acceptKeyword("KEY"):
+			name, err := p.parseIdentifier()
+			if err != nil {
+				return err
+			}
+			table.dropIndex(name)
+		case p.acceptKeywords("FOREIGN", "KEY"):
+			name, err := p.parseIdentifier()
+			if err != nil {
+				return err
+			}
+			table.dropForeignKey(name)
+		case p.acceptKeyword("CONSTRAINT"), p.acceptKeyword("CHECK"):
+			name, err := p.parseIdentifier()
+			if err != nil {
+				return err
+			}
+			table.dropIndex(name)
+			table.dropForeignKey(name)
+		default:
+			p.acceptKeyword("COLUMN")
+			name, err := p.parseIdentifier()
+			if err != nil {
+				return err
+			}
+			table.dropColumn(name)
+		}
+	case p.acceptKeyword("MODIFY"):
+		p.acceptKeyword("COLUMN")
+		column, err := p.parseColumnDefinition(table)
+		if err != nil {
+			return err
+		}
+		return table.replaceColumn(p, column.name, column)
+	case p.acceptKeyword("CHANGE"):
+		p.acceptKeyword("COLUMN")
+		old, err := p.parseIdentifier()
+		if err != nil {
+			return err
+		}
+		column, err := p.parseColumnDefinition(table)
+		if err != nil {
+			return err
+		}
+		return table.replaceColumn(p, old, column)
+	case p.acceptKeyword("RENAME"):
+		switch {
+		case p.acceptKeyword("COLUMN"):
+			old, err := p.parseIdentifier()
+			if err != nil {
+				return err
+			}
+			p.acceptKeyword("TO")
+			name, err := p.parseIdentifier()
+			if err != nil {
+				return err
+			}
+			table.renameColumn(old, name)
+		case p.acceptKeyword("INDEX"), p.acceptKeyword("KEY"):
+			old, err := p.parseIdentifier()
+			if err != nil {
+				return err
+			}
+			p.acceptKeyword("TO")
+			name, err := p.parseIdentifier()
+			if err != nil {
+				return err
+			}
+			for _, index := range table.indexes {
+				if strings.EqualFold(index.name, old) {
+					index.name = name
+				}
+			}
+		default:
+			if !p.acceptKeyword("TO") {
+				p.acceptKeyword("AS")
+			}
+			return s.renameTable(p, table)
+		}
+	case p.acceptKeyword("ALTER"):
+		p.acceptKeyword("COLUMN")
+		name, err := p.parseIdentifier()
+		if err != nil {
+			return err
+		}
+		column := table.column(name)
+		if column == nil {
+			return fmt.Errorf("column %s does not exist", name)
+		}
+		switch {
+		case p.acceptKeywords("SET", "DEFAULT"):
+			if column.defaultValue, column.defaultGenerated, err = p.parseDefault(); err != nil {
+				return err
+			}
+		case p.acceptKeywords("DROP", "DEFAULT"):
+			column.defaultValue, column.defaultGenerated = nil, false
+		}
+	case p.acceptKeyword("COMMENT"):
+		p.accept("=")
+		table.comment = p.next().text
+	}
+	// table options and unsupported specifications
+	p.skipDefinition()
+	return nil
+}
+
+// addDefinition applies ADD [COLUMN] of ALTER TABLE, including FIRST and AFTER
+func (t *ddlTable) addDefinition(p *ddlParser) error {
+	columns := len(t.columns)
+	if err := p.parseCreateDefinition(t); err != nil {
+		return err
+	}
+	if len(t.columns) == columns {
+		// an index or a constraint was added
+		return nil
+	}
+	column := t.columns[len(t.columns)-1]
+	t.columns = t.columns[:len(t.columns)-1]
+	return t.insertColumn(p, column, columns)
+}
+
+// replaceColumn applies MODIFY and CHANGE, the column keeps its position unless FIRST or AFTER is given
+func (t *ddlTable) replaceColumn(p *ddlParser, old string, column *ddlColumn) error {
+	position := -1
+	for i, c := range t.columns {
+		if strings.EqualFold(c.name, old) {
+			position = i
+		}
+	}
+	if position < 0 {
+		return fmt.Errorf("column %s does not exist", old)
+	}
+	t.columns = append(t.columns[:position], t.columns[position+1:]...)
+	t.renameColumn(old, column.name)
+	for _, name := range t.primaryKey {
+		if strings.EqualFold(name, column.name) {
+			column.nullable = false
+		}
+	}
+	return t.insertColumn(p, column, position)
+}
+
+// insertColumn inserts the column at FIRST, AFTER column or the given position
+func (t *ddlTable) insertColumn(p *ddlParser, column *ddlColumn, position int) error {
+	if p.acceptKeyword("FIRST") {
+		position = 0
+	} else if p.acceptKeyword("AFTER") {
+		after, err := p.parseIdentifier()
+		if err != nil {
+			return err
+		}
+		position = -1
+		for i, c := range t.columns {
+			if strings.EqualFold(c.name, after) {
+				position = i + 1
+			}
+		}
+		if position < 0 {
+			return fmt.Errorf("column %s does not exist", after)
+		}
+	}
+	t.columns = append(t.columns, nil)
+	copy(t.columns[position+1:], t.columns[position:])
+	t.columns[position] = column
+	return nil
+}
+
+func (t *ddlTable) renameColumn(old string, name string) {
+	if c := t.column(old); c != nil {
+		c.name = name
+	}
+	rename := func(columns []string) {
+		for i, c := range columns {
+			if strings.EqualFold(c, old) {
+				columns[i] = name
+			}
+		}
+	}
+	rename(t.primaryKey)
+	for _, index := range t.indexes {
+		rename(index.columns)
+	}
+	for _, fk := range t.foreignKeys {
+		rename(fk.columns)
+	}
+}
+
+// dropColumn removes the column and, like mysql, removes it from the keys using it
+func (t *ddlTable) dropColumn(name string) {
+	remove := func(columns []string) []string {
+		var kept []string
+		for _, c := range columns {
+			if !strings.EqualFold(c, name) {
+				kept = append(kept, c)
+			}
+		}
+		return kept
+	}
+	for i, c := range t.columns {
+		if strings.EqualFold(c.name, name) {
+			t.columns = append(t.columns[:i], t.columns[i+1:]...)
+			break
+		}
+	}
+	t.primaryKey = remove(t.primaryKey)
+	var indexes []*ddlIndex
+	for _, index := range t.indexes {
+		if index.lengths != nil {
+			var lengths []int
+			for i, c := range index.columns {
+				if !strings.EqualFold(c, name) {
+					lengths = append(lengths, index.lengths[i])
+				}
+			}
+			index.lengths = lengths
+		}
+		if index.columns = remove(index.columns); len(index.columns) > 0 {
+			indexes = append(indexes, index)
+		}
+	}
+	t.indexes = indexes
+	var foreignKeys []*ddlForeignKey
+	for _, fk := range t.foreignKeys {
+		if fk.columns = remove(fk.columns); len(fk.columns) > 0 {
+			foreignKeys = append(foreignKeys, fk)
+		}
+	}
+	t.foreignKeys = foreignKeys
+}
+
+func (t *ddlTable) dropIndex(name string) {
+	for i, index := range t.indexes {
+		if strings.EqualFold(index.name, name) {
+			t.indexes = append(t.indexes[:i], t.indexes[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *ddlTable) dropForeignKey(name string) {
+	for i, fk := range t.foreignKeys {
+		if strings.EqualFold(fk.name, name) {
+			t.foreignKeys = append(t.foreignKeys[:i], t.foreignKeys[i+1:]...)
+			return
+		}
+	}
+}
+
+// columnExtra returns the EXTRA of the column, expression defaults are DEFAULT_GENERATED like in mysql 8
+func (c *ddlColumn) columnExtra() string {
+	if c.defaultGenerated {
+		return strings.TrimSpace("DEFAULT_GENERATED " + c.extra)
+	}
+	return c.extra
+}
+
+// table converts the parsed table to the Table read from INFORMATION_SCHEMA
+func (t *ddlTable) table() *Table {
+	table := &Table{Name: t.name, Comment: t.comment, Driver: "mysql"}
+	for _, c := range t.columns {
+		table.Columns = append(table.Columns, &Column{
+			Name:       c.name,
+			DataType:   c.dataType,
+			ColumnType: c.columnType,
+			Nullable:   c.nullable,
+			Key:        t.columnKey(c.name),
+			Default:    c.defaultValue,
+			Extra:      c.columnExtra(),
+			Comment:    c.comment,
+		})
+	}
+	if len(t.primaryKey) > 0 {
+		table.Indexes = append(table.Indexes, &Index{Name: "PRIMARY", Primary: true, Unique: true, Columns: t.primaryKey})
+	}
+	for _, index := range t.indexes {
+		table.Indexes = append(table.Indexes, &Index{Name: index.name, Unique: index.unique, Kind: index.kind, Columns: index.columns, Lengths: index.lengths})
+	}
+	for _, fk := range t.foreignKeys {
+		table.ForeignKeys = append(table.ForeignKeys, fk.foreignKey(t.name))
+	}
+	return table
+}
+
+func (fk *ddlForeignKey) foreignKey(table string) *ForeignKey {
+	return &ForeignKey{Name: fk.name, Table: table, Columns: fk.columns, RefTable: fk.refTable, RefColumns: fk.refColumns}
+}
+
+// columnKey returns the COLUMN_KEY mysql would report for the column
+func (t *ddlTable) columnKey(column string) string {
+	for _, c := range t.primaryKey {
+		if strings.EqualFold(c, column) {
+			return "PRI"
+		}
+	}
+	key := ""
+	for _, index := range t.indexes {
+		if len(index.columns) == 0 || !strings.EqualFold(index.columns[0], column) {
+			continue
+		}
+		if index.unique && len(index.columns) == 1 {
+			return "UNI"
+		}
+		key = "MUL"
+	}
+	for _, fk := range t.foreignKeys {
+		if len(fk.columns) > 0 && strings.EqualFold(fk.columns[0], column) {
+			key = "MUL"
+		}
+	}
+	return key
+}
+
+func (t *ddlTable) column(name string) *ddlColumn {
+	for _, c := range t.columns {
+		if strings.EqualFold(c.name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+const (
+	ddlWord = iota
+	ddlQuoted
+	ddlString
+	ddlNumber
+	ddlSymbol
+)
+
+type ddlToken struct {
+	kind int
+	text string
+}
+
+// lexDDL splits a mysql script in tokens, comments are dropped
+func lexDDL(script string) ([]ddlToken, error) {
+	var tokens []ddlToken
+	runes := []rune(script)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '#' || (r == '-' && i+1 < len(runes) && runes[i+1] == '-' && (i+2 == len(runes) || unicode.IsSpace(runes[i+2]))):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			// conditional comments (/*!40101 ... */) of mysqldump are dropped as well
+			j := i + 2
+			for j+1 < len(runes) && !(runes[j] == '*' && runes[j+1] == '/') {
+				j++
+			}
+			if j+1 >= len(runes) {
+				return nil, errors.New("unterminated comment")
+			}
+			i = j + 2
+		case r == '`' || r == '\'' || r == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(runes); j++ {
+				if runes[j] == '\\' && r != '`' && j+1 < len(runes) {
+					j++
+					b.WriteRune(unescapeDDL(runes[j]))
+					continue
+				}
+				if runes[j] == r {
+					// a doubled quote is an escaped quote
+					if j+1 < len(runes) && runes[j+1] == r {
+						b.WriteRune(r)
+						j++
+						continue
+					}
+					break
+				}
+				b.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated quote %c", r)
+			}
+			kind := ddlString
+			if r == '`' {
+				kind = ddlQuoted
+			}
+			tokens = append(tokens, ddlToken{kind, b.String()})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			// identifiers may start with digits
+			if j < len(runes) && (unicode.IsLetter(runes[j]) || runes[j] == '_') {
+				for j < len(runes) && isDDLWordRune(runes[j]) {
+					j++
+				}
+				tokens = append(tokens, ddlToken{ddlWord, string(runes[i:j])})
+			} else {
+				tokens = append(tokens, ddlToken{ddlNumber, string(runes[i:j])})
+			}
+			i = j
+		case isDDLWordRune(r):
+			j := i
+			for j < len(runes) && isDDLWordRune(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, ddlToken{ddlWord, string(runes[i:j])})
+			i = j
+		default:
+			tokens = append(tokens, ddlToken{ddlSymbol, string(r)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isDDLWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'
+}
+
+func unescapeDDL(r rune) rune {
+	switch r {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case '0':
+		return 0
+	}
+	return r
+}
+
+func splitDDLStatements(tokens []ddlToken) [][]ddlToken {
+	var statements [][]ddlToken
+	start := 0
+	for i, t := range tokens {
+		if t.kind == ddlSymbol && t.text == ";" {
+			if i > start {
+				statements = append(statements, tokens[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		statements = append(statements, tokens[start:])
+	}
+	return statements
+}
+
+type ddlParser struct {
+	tokens []ddlToken
+	pos    int
+}
+
+func (p *ddlParser) peek() ddlToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ddlToken{kind: -1}
+}
+
+func (p *ddlParser) next() ddlToken {
+	t := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+func (p *ddlParser) eof() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *ddlParser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == ddlWord && strings.EqualFold(t.text, keyword)
+}
+
+func (p *ddlParser) isSymbol(symbol string) bool {
+	t := p.peek()
+	return t.kind == ddlSymbol && t.text == symbol
+}
+
+func (p *ddlParser) acceptKeyword(keyword string) bool {
+	if p.isKeyword(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// acceptKeywords consumes the sequence of keywords only if all of them match
+func (p *ddlParser) acceptKeywords(keywords ...string) bool {
+	for i, keyword := range keywords {
+		if p.pos+i >= len(p.tokens) {
+			return false
+		}
+		t := p.tokens[p.pos+i]
+		if t.kind != ddlWord || !strings.EqualFold(t.text, keyword) {
+			return false
+		}
+	}
+	p.pos += len(keywords)
+	return true
+}
+
+func (p *ddlParser) accept(symbol string) bool {
+	if p.isSymbol(symbol) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *ddlParser) expect(symbol string) error {
+	if !p.accept(symbol) {
+		return p.errorf("expected %q", symbol)
+	}
+	return nil
+}
+
+func (p *ddlParser) errorf(format string, args ...interface{}) error {
+	near := p.peek().text
+	if p.eof() {
+		near = "end of statement"
+	}
+	return fmt.Errorf(format+" near %q", append(args, near)...)
+}
+
+func (p *ddlParser) parseIdentifier() (string, error) {
+	t := p.peek()
+	if t.kind != ddlWord && t.kind != ddlQuoted && t.kind != ddlString {
+		return "", p.errorf("expected identifier")
+	}
+	p.pos++
+	return t.text, nil
+}
+
+// parseTableName reads [schema.]table and returns the table
+func (p *ddlParser) parseTableName() (string, error) {
+	name, err := p.parseIdentifier()
+	if err != nil {
+		return "", err
+	}
+	for p.accept(".") {
+		if name, err = p.parseIdentifier(); err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// skipParens consumes a balanced parenthesised group and returns its source
+func (p *ddlParser) skipParens() (string, error) {
+	if err := p.expect("("); err != nil {
+		return "", err
+	}
+	var parts []string
+	depth := 1
+	for !p.eof() {
+		t := p.next()
+		if t.kind == ddlSymbol && t.text == "(" {
+			depth++
+		}
+		if t.kind == ddlSymbol && t.text == ")" {
+			depth--
+			if depth == 0 {
+				return strings.Join(parts, ""), nil
+			}
+		}
+		parts = append(parts, t.source())
+	}
+	return "", p.errorf("unbalanced parenthesis")
+}
+
+// source returns the token as it would be written in sql
+func (t ddlToken) source() string {
+	switch t.kind {
+	case ddlString:
+		return "'" + strings.Replace(t.text, "'", "''", -1) + "'"
+	case ddlQuoted:
+		return "`" + t.text + "`"
+	}
+	return t.text
+}
+
+func (p *ddlParser) parseCreateTable() (*ddlTable, error) {
+	p.acceptKeywords("IF", "NOT", "EXISTS")
+	name, err := p.parseTableName()
+	if err != nil {
+		return nil, err
+	}
+	// CREATE TABLE ... LIKE/SELECT has no column definitions to read
+	if !p.accept("(") {
+		return nil, nil
+	}
+
+	table := &ddlTable{name: name}
+	for {
+		if err := p.parseCreateDefinition(table); err != nil {
+			return nil, fmt.Errorf("table %s: %s", name, err)
+		}
+		if p.accept(",") {
+			continue
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, fmt.Errorf("table %s: %s", name, err)
+		}
+		break
+	}
+
+	// table options
+	for !p.eof() {
+		if p.acceptKeyword("COMMENT") {
+			p.accept("=")
+			table.comment = p.next().text
+			continue
+		}
+		p.next()
+	}
+	return table, nil
+}
+
+// parseCreateDefinition reads one column or index definition of CREATE TABLE (and ALTER TABLE ADD)
+func (p *ddlParser) parseCreateDefinition(table *ddlTable) error {
+	constraint := ""
+	if p.acceptKeyword("CONSTRAINT") {
+		if !p.isKeyword("PRIMARY") && !p.isKeyword("UNIQUE") && !p.isKeyword("FOREIGN") && !p.isKeyword("CHECK") {
+			constraint, _ = p.parseIdentifier()
+		}
+	}
+
+	switch {
+	case p.acceptKeywords("PRIMARY", "KEY"):
+		p.parseIndexName()
+		columns, err := p.parseKeyParts()
+		if err != nil {
+			return err
+		}
+		p.skipDefinition()
+		table.primaryKey = columns
+		for _, name := range columns {
+			if c := table.column(name); c != nil {
+				c.nullable = false
+			}
+		}
+	case p.isKeyword("UNIQUE"), p.isKeyword("KEY"), p.isKeyword("INDEX"), p.isKeyword("FULLTEXT"), p.isKeyword("SPATIAL"):
+		index := &ddlIndex{}
+		switch kind := strings.ToUpper(p.next().text); kind {
+		case "UNIQUE":
+			index.unique = true
+			index.kind = "UNIQUE"
+		case "FULLTEXT", "SPATIAL":
+			index.kind = kind
+		}
+		if !p.acceptKeyword("KEY") {
+			p.acceptKeyword("INDEX")
+		}
+		index.name = p.parseIndexName()
+		if index.name == "" {
+			index.name = constraint
+		}
+		columns, lengths, err := p.parseIndexKeyParts()
+		if err != nil {
+			return err
+		}
+		p.skipDefinition()
+		index.columns, index.lengths = columns, lengths
+		if index.name == "" && len(columns) > 0 {
+			index.name = columns[0]
+		}
+		table.indexes = append(table.indexes, index)
+	case p.acceptKeywords("FOREIGN", "KEY"):
+		fk := &ddlForeignKey{name: constraint}
+		if name := p.parseIndexName(); fk.name == "" {
+			fk.name = name
+		}
+		columns, err := p.parseKeyParts()
+		if err != nil {
+			return err
+		}
+		fk.columns = columns
+		if p.acceptKeyword("REFERENCES") {
+			if fk.refTable, err = p.parseTableName(); err != nil {
+				return err
+			}
+			if fk.refColumns, err = p.parseKeyParts(); err != nil {
+				return err
+			}
+		}
+		p.skipDefinition()
+		table.foreignKeys = append(table.foreignKeys, fk)
+	case p.isKeyword("CHECK"):
+		p.skipDefinition()
+	default:
+		column, err := p.parseColumnDefinition(table)
+		if err != nil {
+			return err
+		}
+		table.columns = append(table.columns, column)
+	}
+	return nil
+}
+
+// parseIndexName reads the optional index name and index type before the key parts
+func (p *ddlParser) parseIndexName() string {
+	name := ""
+	if !p.isSymbol("(") && !p.isKeyword("USING") {
+		name, _ = p.parseIdentifier()
+	}
+	if p.acceptKeyword("USING") {
+		p.next()
+	}
+	return name
+}
+
+// parseKeyParts reads (col[(length)] [ASC|DESC], ...) and returns the column names,
+// index options that follow are left to the caller
+func (p *ddlParser) parseKeyParts() ([]string, error) {
+	columns, _, err := p.parseIndexKeyParts()
+	return columns, err
+}
+
+// parseIndexKeyParts reads the key parts like parseKeyParts and the prefix length of each column
+func (p *ddlParser) parseIndexKeyParts() ([]string, []int, error) {
+	if err := p.expect("("); err != nil {
+		return nil, nil, err
+	}
+	var columns []string
+	var lengths []int
+	for {
+		if p.isSymbol("(") {
+			// functional key part
+			if _, err := p.skipParens(); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			name, err := p.parseIdentifier()
+			if err != nil {
+				return nil, nil, err
+			}
+			length := 0
+			if p.isSymbol("(") {
+				prefix, err := p.skipParens()
+				if err != nil {
+					return nil, nil, err
+				}
+				length, _ = strconv.Atoi(strings.TrimSpace(prefix))
+			}
+			columns = append(columns, name)
+			lengths = append(lengths, length)
+		}
+		if !p.acceptKeyword("ASC") {
+			p.acceptKeyword("DESC")
+		}
+		if p.accept(",") {
+			continue
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, nil, err
+		}
+		for _, length := range lengths {
+			if length > 0 {
+				return columns, lengths, nil
+			}
+		}
+		return columns, nil, nil
+	}
+}
+
+// skipDefinition consumes tokens up to the end of the current definition
+func (p *ddlParser) skipDefinition() {
+	for !p.eof() && !p.isSymbol(",") && !p.isSymbol(")") {
+		if p.isSymbol("(") {
+			p.skipParens()
+			continue
+		}
+		p.next()
+	}
+}
+
+// ddlTypeAliases maps the synonyms accepted by mysql to the DATA_TYPE it reports
+var ddlTypeAliases = map[string]string{
+	"integer":   "int",
+	"int1":      "tinyint",
+	"int2":      "smallint",
+	"int3":      "mediumint",
+	"int4":      "int",
+	"int8":      "bigint",
+	"middleint": "mediumint",
+	"dec":       "decimal",
+	"numeric":   "decimal",
+	"fixed":     "decimal",
+	"real":      "double",
+	"float4":    "float",
+	"float8":    "double",
+	"bool":      "tinyint",
+	"boolean":   "tinyint",
+	"serial":    "bigint",
+	"character": "char",
+}
+
+func (p *ddlParser) parseColumnDefinition(table *ddlTable) (*ddlColumn, error) {
+	name, err := p.parseIdentifier()
+	if err != nil {
+		return nil, err
+	}
+	column := &ddlColumn{name: name, nullable: true}
+
+	t := p.next()
+	if t.kind != ddlWord {
+		return nil, p.errorf("expected data type of column %s", name)
+	}
+	dataType := strings.ToLower(t.text)
+	if dataType == "double" {
+		p.acceptKeyword("PRECISION")
+	}
+	if dataType == "character" || dataType == "national" {
+		if p.acceptKeyword("VARYING") {
+			dataType = "varchar"
+		} else if p.isKeyword("CHAR") || p.isKeyword("VARCHAR") {
+			dataType = strings.ToLower(p.next().text)
+		}
+	}
+	columnType := dataType
+	if alias, ok := ddlTypeAliases[dataType]; ok {
+		dataType = alias
+		columnType = alias
+	}
+	if p.isSymbol("(") {
+		args, err := p.skipParens()
+		if err != nil {
+			return nil, err
+		}
+		columnType += "(" + args + ")"
+	}
+	switch strings.ToLower(t.text) {
+	case "bool", "boolean":
+		columnType = "tinyint(1)"
+	case "serial":
+		columnType = "bigint unsigned"
+		column.nullable = false
+		column.extra = "auto_increment"
+		table.indexes = append(table.indexes, &ddlIndex{name: name, unique: true, kind: "UNIQUE", columns: []string{name}})
+	}
+	column.dataType = dataType
+
+	// FIRST and AFTER only appear in ALTER TABLE and are read by the caller
+	for !p.eof() && !p.isSymbol(",") && !p.isSymbol(")") && !p.isKeyword("FIRST") && !p.isKeyword("AFTER") {
+		switch {
+		case p.acceptKeyword("UNSIGNED"):
+			columnType += " unsigned"
+		case p.acceptKeyword("ZEROFILL"):
+			columnType += " zerofill"
+		case p.acceptKeywords("NOT", "NULL"):
+			column.nullable = false
+		case p.acceptKeyword("NULL"):
+			column.nullable = true
+		case p.acceptKeyword("DEFAULT"):
+			value, generated, err := p.parseDefault()
+			if err != nil {
+				return nil, err
+			}
+			column.defaultValue, column.defaultGenerated = value, generated
+		case p.acceptKeywords("ON", "UPDATE"):
+			value, _, err := p.parseDefault()
+			if err != nil {
+				return nil, err
+			}
+			if value != nil {
+				column.extra = strings.TrimSpace(column.extra + " on update " + *value)
+			}
+		case p.acceptKeyword("AUTO_INCREMENT"):
+			column.extra = strings.TrimSpace("auto_increment " + column.extra)
+		case p.acceptKeyword("COMMENT"):
+			column.comment = p.next().text
+		case p.acceptKeywords("PRIMARY", "KEY"), p.acceptKeyword("KEY"):
+			table.primaryKey = []string{name}
+			column.nullable = false
+		case p.acceptKeyword("UNIQUE"):
+			p.acceptKeyword("KEY")
+			table.indexes = append(table.indexes, &ddlIndex{name: name, unique: true, kind: "UNIQUE", columns: []string{name}})
+		case p.acceptKeyword("CHARACTER"), p.acceptKeyword("CHARSET"), p.acceptKeyword("COLLATE"),
+			p.acceptKeyword("COLUMN_FORMAT"), p.acceptKeyword("STORAGE"), p.acceptKeyword("SRID"):
+			p.acceptKeyword("SET")
+			p.accept("=")
+			p.next()
+		case p.acceptKeywords("GENERATED", "ALWAYS"), p.isKeyword("AS"):
+			p.acceptKeyword("AS")
+			if _, err := p.skipParens(); err != nil {
+				return nil, err
+			}
+			if p.isKeyword("VIRTUAL") || p.isKeyword("STORED") {
+				column.extra = strings.ToUpper(p.next().text) + " GENERATED"
+			} else {
+				column.extra = "VIRTUAL GENERATED"
+			}
+		case p.acceptKeyword("REFERENCES"):
+			ref, err := p.parseTableName()
+			if err != nil {
+				return nil, err
+			}
+			refColumns, err := p.parseKeyParts()
+			if err != nil {
+				return nil, err
+			}
+			// ON DELETE/ON UPDATE actions, REFERENCES is the last column attribute
+			p.skipDefinition()
+			table.foreignKeys = append(table.foreignKeys, &ddlForeignKey{columns: []string{name}, refTable: ref, refColumns: refColumns})
+		case p.isSymbol("("):
+			p.skipParens()
+		default:
+			// CHECK, VISIBLE, INVISIBLE, ENGINE_ATTRIBUTE...
+			p.next()
+		}
+	}
+	column.columnType = columnType
+	return column, nil
+}
+
+// parseDefault reads a DEFAULT value, NULL is returned as nil and expressions are reported as generated
+func (p *ddlParser) parseDefault() (*string, bool, error) {
+	if p.acceptKeyword("NULL") {
+		return nil, false, nil
+	}
+	if p.isSymbol("(") {
+		expr, err := p.skipParens()
+		if err != nil {
+			return nil, false, err
+		}
+		return &expr, true, nil
+	}
+
+	t := p.next()
+	value := t.text
+	generated := false
+	switch t.kind {
+	case ddlSymbol:
+		// signed numbers
+		if (value == "-" || value == "+") && p.peek().kind == ddlNumber {
+			value += p.next().text
+		}
+	case ddlWord:
+		// b'0', x'1F' and charset introducers such as _utf8mb4'abc'
+		if p.peek().kind == ddlString {
+			value = p.next().text
+			if strings.EqualFold(t.text, "b") || strings.EqualFold(t.text, "x") {
+				value = t.text + "'" + value + "'"
+			}
+			break
+		}
+		// TRUE and FALSE are stored as numbers
+		if strings.EqualFold(value, "true") {
+			value = "1"
+			break
+		}
+		if strings.EqualFold(value, "false") {
+			value = "0"
+			break
+		}
+		// CURRENT_TIMESTAMP, NOW() and precision like CURRENT_TIMESTAMP(3)
+		if p.isSymbol("(") {
+			args, err := p.skipParens()
+			if err != nil {
+				return nil, false, err
+			}
+			if args != "" {
+				value += "(" + args + ")"
+			}
+		}
+		if strings.EqualFold(value, "now") || strings.EqualFold(value, "current_timestamp") {
+			value = "CURRENT_TIMESTAMP"
+		}
+		generated = true
+	}
+	return &value, generated, nil
+}
@@ -0,0 +1,139 @@
+package db2struct
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testDDL = `-- MySQL dump 10.13  Distrib 5.7.26
+/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+
+DROP TABLE IF EXISTS ` + "`orders`" + `;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+CREATE TABLE ` + "`orders`" + ` (
+  ` + "`id`" + ` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  ` + "`order_no`" + ` varchar(32) COLLATE utf8mb4_bin NOT NULL COMMENT 'business ''order'' number',
+  ` + "`user_id`" + ` int(11) NOT NULL,
+  ` + "`status`" + ` enum('new','paid') NOT NULL DEFAULT 'new',
+  ` + "`amount`" + ` decimal(10,2) DEFAULT '-1.00',
+  ` + "`paid`" + ` tinyint(1) NOT NULL DEFAULT b'0',
+  ` + "`note`" + ` text,
+  ` + "`created_at`" + ` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ` + "`updated_at`" + ` timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (` + "`id`" + `),
+  UNIQUE KEY ` + "`uk_order_no`" + ` (` + "`order_no`" + `),
+  KEY ` + "`idx_user_status`" + ` (` + "`user_id`" + `,` + "`status`" + `),
+  FULLTEXT KEY ` + "`ft_note`" + ` (` + "`note`" + `),
+  CONSTRAINT ` + "`fk_user`" + ` FOREIGN KEY (` + "`user_id`" + `) REFERENCES ` + "`users`" + ` (` + "`id`" + `) ON DELETE CASCADE
+) ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4 COMMENT='customer orders';
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+CREATE TABLE users (id INT PRIMARY KEY, email VARCHAR(255) UNIQUE, active BOOLEAN DEFAULT TRUE);
+INSERT INTO users VALUES (1, 'a@b.c', 1);
+`
+
+func TestParseMysqlDDL(t *testing.T) {
+	schema := newDDLSchema()
+	err := schema.exec(testDDL)
+
+	Convey("Should be able to parse a mysqldump file", t, func() {
+		So(err, ShouldBeNil)
+		So(schema.tables, ShouldContainKey, "orders")
+		So(schema.tables, ShouldContainKey, "users")
+	})
+
+	orders := schema.tables["orders"]
+	Convey("Should read columns, keys and table options", t, func() {
+		So(len(orders.columns), ShouldEqual, 9)
+		So(orders.comment, ShouldEqual, "customer orders")
+		So(orders.primaryKey, ShouldResemble, []string{"id"})
+		So(len(orders.indexes), ShouldEqual, 3)
+		So(orders.indexes[1].columns, ShouldResemble, []string{"user_id", "status"})
+		So(orders.indexes[2].kind, ShouldEqual, "FULLTEXT")
+		So(orders.foreignKeys[0].refTable, ShouldEqual, "users")
+	})
+
+	table := orders.table()
+	Convey("Should convert the parsed table to a Table", t, func() {
+		So(*table.Column("id"), ShouldResemble, Column{
+			Name: "id", DataType: "bigint", ColumnType: "bigint(20) unsigned", Nullable: false, Key: "PRI", Extra: "auto_increment",
+		})
+		So(table.Comment, ShouldEqual, "customer orders")
+		So(table.Column("order_no").Key, ShouldEqual, "UNI")
+		So(table.Column("order_no").Comment, ShouldEqual, "business 'order' number")
+		So(table.Column("user_id").Key, ShouldEqual, "MUL")
+		So(table.Column("status").ColumnType, ShouldEqual, "enum('new','paid')")
+		So(*table.Column("status").Default, ShouldEqual, "new")
+		So(table.Column("amount").Nullable, ShouldBeTrue)
+		So(*table.Column("amount").Default, ShouldEqual, "-1.00")
+		So(*table.Column("paid").Default, ShouldEqual, "b'0'")
+		So(table.Column("note").Default, ShouldBeNil)
+		So(*table.Column("created_at").Default, ShouldEqual, "CURRENT_TIMESTAMP")
+		So(table.Column("updated_at").Extra, ShouldEqual, "DEFAULT_GENERATED on update CURRENT_TIMESTAMP(3)")
+		So(table.Column("created_at").Extra, ShouldEqual, "DEFAULT_GENERATED")
+		So(table.Column("status").Extra, ShouldEqual, "")
+		So(table.Indexes[0], ShouldResemble, &Index{Name: "PRIMARY", Primary: true, Unique: true, Columns: []string{"id"}})
+		So(table.Indexes[1], ShouldResemble, &Index{Name: "uk_order_no", Unique: true, Kind: "UNIQUE", Columns: []string{"order_no"}})
+		So(table.Indexes[3], ShouldResemble, &Index{Name: "ft_note", Kind: "FULLTEXT", Columns: []string{"note"}})
+	})
+
+	users := schema.tables["users"].table()
+	Convey("Should read inline keys and type aliases", t, func() {
+		So(users.Column("id").Key, ShouldEqual, "PRI")
+		So(users.Column("id").Nullable, ShouldBeFalse)
+		So(users.Column("email").Key, ShouldEqual, "UNI")
+		So(users.Column("active").DataType, ShouldEqual, "tinyint")
+		So(users.Column("active").ColumnType, ShouldEqual, "tinyint(1)")
+		So(*users.Column("active").Default, ShouldEqual, "1")
+	})
+}
+
+func TestGetColumnsFromMysqlDDL(t *testing.T) {
+	columMap, err := GetColumnsFromMysqlDDL("tests/mariadb.sql", "all_data_types")
+	Convey("Should be able to read the test schema", t, func() {
+		So(err, ShouldBeNil)
+		So(columMap, ShouldNotBeNil)
+		So(len(columMap.Columns), ShouldEqual, 28)
+		So(columMap.Column("float").ColumnType, ShouldEqual, "float(10,2)")
+		So(columMap.Column("set").ColumnType, ShouldEqual, "set('1','2','3')")
+	})
+
+	columMap, err = GetColumnsFromMysqlDDL("tests/mariadb.sql", "doesnotexists")
+	Convey("Should get an error for an unknown table", t, func() {
+		So(err, ShouldNotBeNil)
+		So(columMap, ShouldBeNil)
+	})
+}
+
+func TestParseIndexPrefixLengths(t *testing.T) {
+	schema := newDDLSchema()
+	err := schema.exec("CREATE TABLE t (a varchar(255), b varchar(255), c int, KEY idx_ab (a(10), b), KEY idx_c (c));" +
+		"CREATE INDEX idx_cb ON t (c, b(20) DESC); ALTER TABLE t DROP COLUMN c;")
+
+	Convey("Should read the prefix lengths of the key parts", t, func() {
+		So(err, ShouldBeNil)
+		table := schema.tables["t"].table()
+		So(len(table.Indexes), ShouldEqual, 2)
+		So(table.Indexes[0], ShouldResemble, &Index{Name: "idx_ab", Columns: []string{"a", "b"}, Lengths: []int{10, 0}})
+		So(table.Indexes[1], ShouldResemble, &Index{Name: "idx_cb", Columns: []string{"b"}, Lengths: []int{20}})
+		So(table.Indexes[1].Length(0), ShouldEqual, 20)
+		So(table.Indexes[0].Position("B"), ShouldEqual, 1)
+	})
+}
+
+func TestDDLForeignKeys(t *testing.T) {
+	schema := newDDLSchema()
+	err := schema.exec(testDDL)
+	tables, errs := schema.lookup([]string{"orders", "users"}, "in the test ddl")
+
+	Convey("Should attach the foreign keys to both tables", t, func() {
+		So(err, ShouldBeNil)
+		So(errs, ShouldResemble, []error{nil, nil})
+		fk := &ForeignKey{Name: "fk_user", Table: "orders", Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}}
+		So(tables[0].ForeignKeys, ShouldResemble, []*ForeignKey{fk})
+		So(tables[0].ReferencedBy, ShouldBeNil)
+		So(tables[1].ForeignKeys, ShouldBeNil)
+		So(tables[1].ReferencedBy, ShouldResemble, []*ForeignKey{fk})
+	})
+}
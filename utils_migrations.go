@@ -0,0 +1,141 @@
+package db2struct
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MigrationsSource reads tables by replaying the up migrations of a golang-migrate or goose directory
+type MigrationsSource struct {
+	Dir string
+}
+
+// Table implements SchemaSource
+func (s *MigrationsSource) Table(name string) (*Table, error) {
+	return GetColumnsFromMigrations(s.Dir, name)
+}
+
+// Tables implements SchemaSource
+func (s *MigrationsSource) Tables() ([]string, error) {
+	schema, err := replayMigrations(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	return schema.tableNames(), nil
+}
+
+// tables replays the migrations once for all the tables
+func (s *MigrationsSource) tables(names []string) ([]*Table, []error) {
+	schema, err := replayMigrations(s.Dir)
+	if err != nil {
+		return failTables(len(names), err)
+	}
+	return schema.lookup(names, "after replaying "+s.Dir)
+}
+
+// GetColumnsFromMigrations Replay the up migrations of a golang-migrate or goose directory and return the table
+func GetColumnsFromMigrations(migrationsDir string, mysqlTable string) (*Table, error) {
+	schema, err := replayMigrations(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, errs := schema.lookup([]string{mysqlTable}, "after replaying "+migrationsDir)
+	return tables[0], errs[0]
+}
+
+// replayMigrations applies every up migration of the directory in version order
+func replayMigrations(migrationsDir string) (*ddlSchema, error) {
+	files, err := migrationFiles(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .sql migrations found in %s", migrationsDir)
+	}
+
+	schema := newDDLSchema()
+	for _, file := range files {
+		content, err := ioutil.ReadFile(filepath.Join(migrationsDir, file))
+		if err != nil {
+			return nil, err
+		}
+		if Debug {
+			fmt.Println("applying: " + file)
+		}
+		if err := schema.exec(gooseUpSection(string(content))); err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+	}
+	return schema, nil
+}
+
+// migrationFiles lists the up migrations of the directory sorted by version,
+// golang-migrate uses <version>_<title>.up.sql and goose <version>_<title>.sql
+func migrationFiles(migrationsDir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") || strings.HasSuffix(name, ".down.sql") {
+			continue
+		}
+		files = append(files, name)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		vi, vj := migrationVersion(files[i]), migrationVersion(files[j])
+		// versions are compared as numbers of any length: 2 < 10 < 20190101120000
+		if len(vi) != len(vj) {
+			return len(vi) < len(vj)
+		}
+		if vi != vj {
+			return vi < vj
+		}
+		return files[i] < files[j]
+	})
+	return files, nil
+}
+
+// migrationVersion returns the leading digits of the file name without leading zeros
+func migrationVersion(name string) string {
+	end := 0
+	for end < len(name) && name[end] >= '0' && name[end] <= '9' {
+		end++
+	}
+	return strings.TrimLeft(name[:end], "0")
+}
+
+// gooseUpSection returns the statements between "-- +goose Up" and "-- +goose Down",
+// files without goose annotations are returned as they are
+func gooseUpSection(content string) string {
+	var up []string
+	annotated, inUp := false, false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		annotation := strings.Fields(strings.TrimLeft(trimmed, "-"))
+		if strings.HasPrefix(trimmed, "--") && len(annotation) > 0 && annotation[0] == "+goose" {
+			if len(annotation) > 1 && annotation[1] == "Up" {
+				annotated, inUp = true, true
+			}
+			if len(annotation) > 1 && annotation[1] == "Down" {
+				annotated, inUp = true, false
+			}
+			continue
+		}
+		if inUp {
+			up = append(up, line)
+		}
+	}
+	if !annotated {
+		return content
+	}
+	return strings.Join(up, "\n")
+}
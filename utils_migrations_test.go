@@ -0,0 +1,65 @@
+package db2struct
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReplayMigrations(t *testing.T) {
+	schema, err := replayMigrations("tests/migrations")
+	Convey("Should be able to replay the up migrations", t, func() {
+		So(err, ShouldBeNil)
+		So(schema.tables, ShouldContainKey, "users")
+		So(schema.tables, ShouldContainKey, "purchases")
+		So(schema.tables, ShouldContainKey, "accounts")
+		So(schema.tables, ShouldNotContainKey, "orders")
+	})
+
+	users := schema.tables["users"]
+	Convey("Should apply ALTER TABLE to the final state", t, func() {
+		var names []string
+		for _, c := range users.columns {
+			names = append(names, c.name)
+		}
+		So(names, ShouldResemble, []string{"id", "full_name", "nickname", "email", "created_at"})
+		So(users.column("nickname").comment, ShouldEqual, "display name")
+		So(users.column("full_name").columnType, ShouldEqual, "varchar(128)")
+		So(users.columnKey("email"), ShouldEqual, "UNI")
+	})
+
+	purchases := schema.tables["purchases"].table()
+	Convey("Should apply MODIFY, DROP and RENAME", t, func() {
+		So(purchases.Name, ShouldEqual, "purchases")
+		So(purchases.Column("legacy_flag"), ShouldBeNil)
+		So(purchases.Column("amount").ColumnType, ShouldEqual, "bigint unsigned")
+		So(purchases.Column("user_id").Key, ShouldEqual, "MUL")
+		So(purchases.Columns[0].Name, ShouldEqual, "amount")
+	})
+
+	Convey("Should only apply the goose up section", t, func() {
+		So(*schema.tables["accounts"].column("balance").defaultValue, ShouldEqual, "10")
+	})
+}
+
+func TestMigrationFiles(t *testing.T) {
+	files, err := migrationFiles("tests/migrations")
+	Convey("Should sort the up migrations by version", t, func() {
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{
+			"000001_create_users.up.sql",
+			"000002_create_orders.up.sql",
+			"000010_alter_users.up.sql",
+			"000011_goose_accounts.sql",
+		})
+	})
+}
+
+func TestMigrationsSourceTables(t *testing.T) {
+	source := &MigrationsSource{Dir: "tests/migrations"}
+	tables, err := source.Tables()
+	Convey("Should list the tables of the final schema", t, func() {
+		So(err, ShouldBeNil)
+		So(tables, ShouldResemble, []string{"accounts", "purchases", "users"})
+	})
+}
@@ -1,144 +1,754 @@
 package db2struct
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
 	"errors"
 	"fmt"
+	"go/token"
+	"io/ioutil"
+	"net"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
-var sortFields []string
+// MysqlSource reads tables from the INFORMATION_SCHEMA of a mysql or mariadb server,
+// all calls share one connection pool which is opened on first use
+type MysqlSource struct {
+	User     string
+	Password string
+	Host     string
+	Port     int
+	// Socket is the path of a unix socket, used instead of Host and Port
+	Socket   string
+	Database string
+	// DSN is a complete go-sql-driver dsn, e.g. user:pass@unix(/tmp/mysql.sock)/db?charset=utf8mb4.
+	// It replaces User, Password, Host, Port and Socket, a Database overrides the one of the dsn
+	DSN string
+	// TLS is true, false or skip-verify. TLSCA, TLSCert and TLSKey are PEM files and enable tls
+	TLS     string
+	TLSCA   string
+	TLSCert string
+	TLSKey  string
+	// Timeout limits connecting, 10s by default. ReadTimeout and WriteTimeout limit the queries
+	Timeout      time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	pool sharedPool
+	// database is the database read, set when the pool is opened
+	database string
+}
+
+const defaultMysqlTimeout = 10 * time.Second
+
+// Table implements SchemaSource
+func (s *MysqlSource) Table(name string) (*Table, error) {
+	tables, errs := s.tables([]string{name})
+	return tables[0], errs[0]
+}
 
-// GetColumnsFromMysqlTable Select column details from information schema and return map of map
-func GetColumnsFromMysqlTable(mariadbUser string, mariadbPassword string, mariadbHost string, mariadbPort int, mariadbDatabase string, mariadbTable string) (*map[string]map[string]string, error) {
+// Tables implements SchemaSource
+func (s *MysqlSource) Tables() ([]string, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	return getMysqlTableNames(db, s.database)
+}
 
-	var err error
-	var db *sql.DB
-	if mariadbPassword != "" {
-		db, err = sql.Open("mysql", mariadbUser+":"+mariadbPassword+"@tcp("+mariadbHost+":"+strconv.Itoa(mariadbPort)+")/"+mariadbDatabase+"?&parseTime=True")
+// Close closes the connection pool
+func (s *MysqlSource) Close() error {
+	return s.pool.close()
+}
+
+func (s *MysqlSource) open() (*sql.DB, error) {
+	return s.pool.open(func() (*sql.DB, error) {
+		cfg, err := s.config()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.DBName == "" {
+			return nil, errors.New("no mysql database, set it in the dsn or with the database")
+		}
+		s.database = cfg.DBName
+		return openMysql(cfg)
+	})
+}
+
+// tables reads the columns of all the tables with a single INFORMATION_SCHEMA query
+func (s *MysqlSource) tables(names []string) ([]*Table, []error) {
+	db, err := s.open()
+	if err != nil {
+		return failTables(len(names), err)
+	}
+	return getMysqlTables(db, s.database, names)
+}
+
+// config returns the driver configuration of the source. The dsn is formatted by the driver,
+// so passwords may contain @ or /
+func (s *MysqlSource) config() (*mysql.Config, error) {
+	cfg := mysql.NewConfig()
+	if s.DSN != "" {
+		var err error
+		cfg, err = mysql.ParseDSN(s.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mysql dsn: %s", err)
+		}
 	} else {
-		db, err = sql.Open("mysql", mariadbUser+"@tcp("+mariadbHost+":"+strconv.Itoa(mariadbPort)+")/"+mariadbDatabase+"?&parseTime=True")
+		cfg.User = s.User
+		cfg.Passwd = s.Password
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+		if s.Socket != "" {
+			cfg.Net = "unix"
+			cfg.Addr = s.Socket
+		}
+	}
+	if s.Database != "" {
+		cfg.DBName = s.Database
+	}
+	cfg.ParseTime = true
+
+	if s.Timeout > 0 {
+		cfg.Timeout = s.Timeout
+	} else if cfg.Timeout == 0 {
+		cfg.Timeout = defaultMysqlTimeout
+	}
+	if s.ReadTimeout > 0 {
+		cfg.ReadTimeout = s.ReadTimeout
+	}
+	if s.WriteTimeout > 0 {
+		cfg.WriteTimeout = s.WriteTimeout
+	}
+
+	if err := s.tlsConfig(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// tlsConfig sets the tls mode, a custom configuration is registered when certificate files are given
+func (s *MysqlSource) tlsConfig(cfg *mysql.Config) error {
+	mode := strings.ToLower(s.TLS)
+	switch mode {
+	case "", "true", "false", "skip-verify":
+	default:
+		return fmt.Errorf("unknown mysql tls mode %s, use true, false or skip-verify", s.TLS)
+	}
+	if s.TLSCA == "" && s.TLSCert == "" && s.TLSKey == "" {
+		if mode != "" {
+			cfg.TLSConfig = mode
+		}
+		return nil
+	}
+	if mode == "false" {
+		return errors.New("mysql tls is disabled but certificate files are given")
 	}
-	defer db.Close()
 
+	config := &tls.Config{InsecureSkipVerify: mode == "skip-verify"}
+	if s.TLSCA != "" {
+		ca, err := ioutil.ReadFile(s.TLSCA)
+		if err != nil {
+			return err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return fmt.Errorf("no PEM certificate found in %s", s.TLSCA)
+		}
+	}
+	if s.TLSCert != "" || s.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(s.TLSCert, s.TLSKey)
+		if err != nil {
+			return fmt.Errorf("invalid mysql client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	// the driver looks tls configurations up by name, one per source
+	name := fmt.Sprintf("db2struct-%p", s)
+	if err := mysql.RegisterTLSConfig(name, config); err != nil {
+		return err
+	}
+	cfg.TLSConfig = name
+	return nil
+}
+
+// openMysql opens the connection pool and pings the server, so a wrong address or credentials
+// fail within the connect timeout with a clear error instead of on the first query
+func openMysql(cfg *mysql.Config) (*sql.DB, error) {
+	db, err := sql.Open("mysql", cfg.FormatDSN())
 	// Check for error in db, note this does not check connectivity but does check uri
 	if err != nil {
-		fmt.Println("Error opening mysql db: " + err.Error())
 		return nil, err
 	}
 
-	// Store colum as map of maps
-	columnDataTypes := make(map[string]map[string]string)
+	// the driver does not cancel the handshake, a server accepting connections without answering would block
+	ping := make(chan error, 1)
+	go func() {
+		ping <- db.Ping()
+	}()
+	select {
+	case err = <-ping:
+	case <-time.After(cfg.Timeout):
+		err = fmt.Errorf("no answer within %s", cfg.Timeout)
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cannot connect to mysql server %s(%s) as %s: %s", cfg.Net, cfg.Addr, cfg.User, err)
+	}
+	return db, nil
+}
+
+// GetTablesFromMysqlDatabase Select the base tables and views of the database from information schema, sorted by name
+func GetTablesFromMysqlDatabase(mariadbUser string, mariadbPassword string, mariadbHost string, mariadbPort int, mariadbDatabase string) ([]string, error) {
+	source := &MysqlSource{User: mariadbUser, Password: mariadbPassword, Host: mariadbHost, Port: mariadbPort, Database: mariadbDatabase}
+	defer source.Close()
+
+	return source.Tables()
+}
+
+func getMysqlTableNames(db *sql.DB, mariadbDatabase string) ([]string, error) {
+	tableQuery := "SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_TYPE IN ('BASE TABLE', 'VIEW') ORDER BY TABLE_NAME ASC"
+
+	if Debug {
+		fmt.Println("running: " + tableQuery)
+	}
+
+	return queryStrings(db, tableQuery, mariadbDatabase)
+}
+
+// queryStrings returns the first column of every row
+func queryStrings(db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// GetColumnsFromMysqlTable Select column details from information schema and return the table
+func GetColumnsFromMysqlTable(mariadbUser string, mariadbPassword string, mariadbHost string, mariadbPort int, mariadbDatabase string, mariadbTable string) (*Table, error) {
+
+	source := &MysqlSource{User: mariadbUser, Password: mariadbPassword, Host: mariadbHost, Port: mariadbPort, Database: mariadbDatabase}
+	defer source.Close()
+
+	return source.Table(mariadbTable)
+}
+
+// getMysqlTables reads the columns of the named tables, a single table is selected by name
+// and several tables with one query over the whole database. Results follow the order of names
+func getMysqlTables(db *sql.DB, mariadbDatabase string, names []string) ([]*Table, []error) {
+	tables := make(map[string]*Table, len(names))
+	for _, name := range names {
+		tables[name] = &Table{Name: name, Driver: "mysql"}
+	}
+
 	// Select columnd data from INFORMATION_SCHEMA
-	columnDataTypeQuery := "SELECT COLUMN_NAME, COLUMN_KEY, DATA_TYPE, IS_NULLABLE FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = ? AND table_name = ? ORDER BY ORDINAL_POSITION ASC"
+	columnDataTypeQuery := "SELECT TABLE_NAME, COLUMN_NAME, COLUMN_KEY, DATA_TYPE, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA, COLUMN_COMMENT FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = ?"
+	args := []interface{}{mariadbDatabase}
+	if len(names) == 1 {
+		columnDataTypeQuery += " AND TABLE_NAME = ?"
+		args = append(args, names[0])
+	}
+	columnDataTypeQuery += " ORDER BY TABLE_NAME, ORDINAL_POSITION ASC"
 
 	if Debug {
 		fmt.Println("running: " + columnDataTypeQuery)
 	}
 
-	rows, err := db.Query(columnDataTypeQuery, mariadbDatabase, mariadbTable)
+	rows, err := db.Query(columnDataTypeQuery, args...)
 
 	if err != nil {
-		fmt.Println("Error selecting from db: " + err.Error())
-		return nil, err
+		return failTables(len(names), err)
 	}
 	if rows != nil {
 		defer rows.Close()
 	} else {
-		return nil, errors.New("No results returned for table")
+		return failTables(len(names), errors.New("No results returned for table"))
 	}
 
 	for rows.Next() {
+		var tableName string
 		var column string
 		var columnKey string
 		var dataType string
+		var columnType string
 		var nullable string
-		rows.Scan(&column, &columnKey, &dataType, &nullable)
+		var columnDefault sql.NullString
+		var extra string
+		var comment string
+		if err := rows.Scan(&tableName, &column, &columnKey, &dataType, &columnType, &nullable, &columnDefault, &extra, &comment); err != nil {
+			return failTables(len(names), fmt.Errorf("reading column %s.%s: %w", tableName, column, err))
+		}
+
+		table, ok := tables[tableName]
+		if !ok {
+			continue
+		}
+		defaultValue, extra := mysqlDefault(columnDefault, extra)
+		table.Columns = append(table.Columns, &Column{
+			Name:       column,
+			DataType:   dataType,
+			ColumnType: columnType,
+			Nullable:   nullable == "YES",
+			Key:        columnKey,
+			Default:    defaultValue,
+			Extra:      extra,
+			Comment:    comment,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return failTables(len(names), err)
+	}
+
+	if err := getMysqlTableComments(db, mariadbDatabase, names, tables); err != nil {
+		return failTables(len(names), err)
+	}
+	if err := getMysqlIndexes(db, mariadbDatabase, names, tables); err != nil {
+		return failTables(len(names), err)
+	}
+	if err := getMysqlForeignKeys(db, mariadbDatabase, names, tables); err != nil {
+		return failTables(len(names), err)
+	}
+
+	result := make([]*Table, len(names))
+	errs := make([]error, len(names))
+	for i, name := range names {
+		if len(tables[name].Columns) == 0 {
+			errs[i] = fmt.Errorf("table %s.%s not found or has no columns", mariadbDatabase, name)
+			continue
+		}
+		result[i] = tables[name]
+	}
+	return result, errs
+}
+
+// mysqlDefault normalizes COLUMN_DEFAULT and EXTRA: mysql 8 marks expression defaults DEFAULT_GENERATED,
+// mariadb quotes literal defaults and reports NULL and expressions such as current_timestamp() unquoted
+func mysqlDefault(columnDefault sql.NullString, extra string) (*string, string) {
+	if !columnDefault.Valid {
+		return nil, extra
+	}
+	value := columnDefault.String
+	if strings.Contains(strings.ToUpper(extra), "DEFAULT_GENERATED") {
+		return &value, extra
+	}
+	if isCurrentTimestamp(value) || strings.HasPrefix(value, "'") || value == "NULL" || strings.HasSuffix(value, ")") {
+		defaultValue, generated := sqlDefault(value)
+		if generated {
+			extra = strings.TrimSpace("DEFAULT_GENERATED " + extra)
+		}
+		return defaultValue, extra
+	}
+	// mysql literals are unquoted
+	return &value, extra
+}
+
+// getMysqlTableComments sets the TABLE_COMMENT and whether the tables are views, selected like in getMysqlTables
+func getMysqlTableComments(db *sql.DB, mariadbDatabase string, names []string, tables map[string]*Table) error {
+	tableCommentQuery := "SELECT TABLE_NAME, TABLE_TYPE, TABLE_COMMENT FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ?"
+	args := []interface{}{mariadbDatabase}
+	if len(names) == 1 {
+		tableCommentQuery += " AND TABLE_NAME = ?"
+		args = append(args, names[0])
+	}
 
-		columnDataTypes[column] = map[string]string{"value": dataType, "nullable": nullable, "primary": columnKey}
-		sortFields = append(sortFields, column)
+	if Debug {
+		fmt.Println("running: " + tableCommentQuery)
 	}
 
-	return &columnDataTypes, err
+	rows, err := db.Query(tableCommentQuery, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, tableType, comment string
+		if err := rows.Scan(&tableName, &tableType, &comment); err != nil {
+			return fmt.Errorf("reading the comment of table %s: %w", tableName, err)
+		}
+		if table, ok := tables[tableName]; ok {
+			table.View = tableType == "VIEW"
+			// the comment of a view is the word VIEW
+			if !table.View {
+				table.Comment = comment
+			}
+		}
+	}
+	return rows.Err()
 }
 
-var pk, createdAtKey, updatedATKey string
-var haveNull bool
+// getMysqlIndexes reads the indexes of the tables from STATISTICS, the primary key first and the others by name
+func getMysqlIndexes(db *sql.DB, mariadbDatabase string, names []string, tables map[string]*Table) error {
+	indexQuery := "SELECT TABLE_NAME, INDEX_NAME, NON_UNIQUE, COLUMN_NAME, SUB_PART, INDEX_TYPE FROM INFORMATION_SCHEMA.STATISTICS WHERE TABLE_SCHEMA = ?"
+	args := []interface{}{mariadbDatabase}
+	if len(names) == 1 {
+		indexQuery += " AND TABLE_NAME = ?"
+		args = append(args, names[0])
+	}
+	indexQuery += " ORDER BY TABLE_NAME, INDEX_NAME <> 'PRIMARY', INDEX_NAME, SEQ_IN_INDEX"
 
-func generateAllImport() string {
-	i := `
-import (
-	"time"
-	
-	"github.com/jinzhu/gorm"
-`
-	if haveNull == true {
-		i = fmt.Sprintf("%s\"gopkg.in/guregu/null.v3\"", i)
+	if Debug {
+		fmt.Println("running: " + indexQuery)
 	}
-	i += `
-)
-`
-	return i
+
+	rows, err := db.Query(indexQuery, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var index *Index
+	var indexTable string
+	for rows.Next() {
+		var tableName, indexName, indexType string
+		var nonUnique int
+		var column sql.NullString
+		var subPart sql.NullInt64
+		if err := rows.Scan(&tableName, &indexName, &nonUnique, &column, &subPart, &indexType); err != nil {
+			return fmt.Errorf("reading index %s of table %s: %w", indexName, tableName, err)
+		}
+
+		table, ok := tables[tableName]
+		if !ok {
+			continue
+		}
+		if index == nil || indexTable != tableName || index.Name != indexName {
+			indexTable = tableName
+			index = &Index{Name: indexName, Primary: indexName == "PRIMARY", Unique: nonUnique == 0}
+			switch {
+			case indexType == "FULLTEXT", indexType == "SPATIAL":
+				index.Kind = indexType
+			case index.Unique && !index.Primary:
+				index.Kind = "UNIQUE"
+			}
+			table.Indexes = append(table.Indexes, index)
+		}
+		// functional key parts have no column
+		if !column.Valid {
+			continue
+		}
+		index.Columns = append(index.Columns, column.String)
+		if subPart.Valid {
+			for len(index.Lengths) < len(index.Columns)-1 {
+				index.Lengths = append(index.Lengths, 0)
+			}
+			index.Lengths = append(index.Lengths, int(subPart.Int64))
+		}
+	}
+	return rows.Err()
 }
 
-func generateImport() string {
-	i := `
-import (
-	"errors"
-	"time"
-	
-	"github.com/jinzhu/gorm"
-`
-	if haveNull == true {
-		i = fmt.Sprintf("%s\"gopkg.in/guregu/null.v3\"", i)
+// getMysqlForeignKeys reads the foreign keys from and to the tables from KEY_COLUMN_USAGE and REFERENTIAL_CONSTRAINTS
+func getMysqlForeignKeys(db *sql.DB, mariadbDatabase string, names []string, tables map[string]*Table) error {
+	foreignKeyQuery := `SELECT k.CONSTRAINT_NAME, k.TABLE_NAME, k.COLUMN_NAME, k.REFERENCED_TABLE_NAME, k.REFERENCED_COLUMN_NAME
+FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE k
+JOIN INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS r ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA AND r.TABLE_NAME = k.TABLE_NAME AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME
+WHERE k.TABLE_SCHEMA = ? AND k.REFERENCED_TABLE_SCHEMA = k.TABLE_SCHEMA`
+	args := []interface{}{mariadbDatabase}
+	if len(names) == 1 {
+		foreignKeyQuery += " AND (k.TABLE_NAME = ? OR k.REFERENCED_TABLE_NAME = ?)"
+		args = append(args, names[0], names[0])
 	}
-	i += `
-)
-`
-	return i
+	foreignKeyQuery += " ORDER BY k.TABLE_NAME, k.CONSTRAINT_NAME, k.ORDINAL_POSITION"
+
+	if Debug {
+		fmt.Println("running: " + foreignKeyQuery)
+	}
+
+	rows, err := db.Query(foreignKeyQuery, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var fks []*ForeignKey
+	for rows.Next() {
+		var name, tableName, column, refTable, refColumn string
+		if err := rows.Scan(&name, &tableName, &column, &refTable, &refColumn); err != nil {
+			return fmt.Errorf("reading foreign key %s of table %s: %w", name, tableName, err)
+		}
+
+		if len(fks) == 0 || fks[len(fks)-1].Table != tableName || fks[len(fks)-1].Name != name {
+			fks = append(fks, &ForeignKey{Name: name, Table: tableName, RefTable: refTable})
+		}
+		fk := fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, column)
+		fk.RefColumns = append(fk.RefColumns, refColumn)
+	}
+	for _, fk := range fks {
+		addForeignKey(tables, fk)
+	}
+	return rows.Err()
 }
 
-// Generate go struct entries for a map[string]interface{} structure
-func generateMysqlTypes(obj map[string]map[string]string, depth int, jsonAnnotation bool, gormAnnotation bool, gureguTypes bool) string {
-	structure := "struct {"
+// generation holds what is learned about one table while it is rendered,
+// so a Generator keeps no state between tables
+type generation struct {
+	pk, createdAtKey, updatedAtKey  string
+	haveNull, haveJSON, havePqArray bool
+	// view is set for database views, whose repository has no write methods
+	view bool
+	// readOnlyHooks is set for the views with gorm annotations, whose models get hooks refusing the writes
+	readOnlyHooks bool
+	// schema qualifies the table names of TableName and of the many2many tags
+	schema string
+	// defaults are the field: value lines of the New<Struct> constructor
+	defaults []string
+	// fields are the go fields of the columns, by lower case column name
+	fields map[string]tplField
+	// primaryKey are the fields of the primary key columns, pk is only set for single column keys
+	primaryKey []tplField
+	// uniqueKeys are the unique indexes other than the primary key, rendered as finder methods
+	uniqueKeys []tplKey
+	// associations are the belongs to and has many fields of the foreign keys, rendered as preloading finders
+	associations []tplAssociation
+	// manyToMany are the many2many fields the table owns, rendered as Add/Remove/Replace/List helpers
+	manyToMany []tplAssociation
+	// imports are the import specs of the mapped types, by go type
+	imports map[string]string
+	// unmapped are the columns without go type
+	unmapped []UnmappedColumn
+	// err is the first invalid type mapping, reported by Render
+	err error
+}
+
+// generateAllImport renders the imports of the model
+func (s *generation) generateAllImport() string {
+	specs := []string{`"time"`, `"github.com/jinzhu/gorm"`}
+	if s.haveJSON == true {
+		specs = append(specs, `"encoding/json"`)
+	}
+	if s.readOnlyHooks == true {
+		specs = append(specs, `"errors"`)
+	}
+	if s.haveNull == true {
+		specs = append(specs, `"gopkg.in/guregu/null.v3"`)
+	}
+	if s.havePqArray == true {
+		specs = append(specs, `"github.com/lib/pq"`)
+	}
+	var types []string
+	for valueType := range s.imports {
+		types = append(types, valueType)
+	}
+	return importBlock(append(specs, s.typeImports(types)...))
+}
+
+// generateImport renders the imports of the repository, which only uses the types of the keys.
+// timeColumns reports whether the table has created or updated columns for the repository to set
+func (s *generation) generateImport(timeColumns bool) string {
+	specs := []string{`"github.com/jinzhu/gorm"`}
+	// only the Create of single column keys reports existing records
+	if len(s.primaryKey) == 1 {
+		specs = append(specs, `"errors"`)
+	}
+	// the read-only repository of a view never sets the time columns
+	if timeColumns && !s.view {
+		specs = append(specs, `"time"`)
+	}
+	return importBlock(append(specs, s.typeImports(s.keyTypes())...))
+}
 
-	l := len(sortFields)
-	for i := 0; i < l; i++ {
-		key := sortFields[i]
-		mysqlType := obj[key]
-		nullable := false
-		if mysqlType["nullable"] == "YES" {
-			nullable = true
+// generateInterfaceImport renders the imports of the repository interface, empty when its key types need none
+func (s *generation) generateInterfaceImport() string {
+	specs := s.typeImports(s.keyTypes())
+	if len(specs) == 0 {
+		return ""
+	}
+	return importBlock(specs)
+}
+
+// keyTypes returns the go types of the primary and unique key fields, used in the repository signatures
+func (s *generation) keyTypes() []string {
+	var types []string
+	for _, field := range s.primaryKey {
+		types = append(types, field.Type)
+	}
+	for _, key := range s.uniqueKeys {
+		for _, field := range key.Fields {
+			types = append(types, field.Type)
+		}
+	}
+	return types
+}
+
+// typeImports returns the import specs of the types, the mapped types and the types of knownImports
+// such as null.Int or json.RawMessage
+func (s *generation) typeImports(types []string) []string {
+	var specs []string
+	for _, valueType := range types {
+		spec, ok := s.imports[valueType]
+		if !ok {
+			_, spec, _ = goType(valueType)
+		}
+		if spec != "" {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// importBlock renders the import specs like goimports: the standard library first, then the other packages,
+// each group sorted by path and every path once
+func importBlock(specs []string) string {
+	seen := make(map[string]bool)
+	var std, others []string
+	for _, spec := range specs {
+		path := spec[strings.Index(spec, `"`):]
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		if strings.Contains(strings.SplitN(strings.Trim(path, `"`), "/", 2)[0], ".") {
+			others = append(others, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	byPath := func(specs []string) func(i, j int) bool {
+		return func(i, j int) bool {
+			return specs[i][strings.Index(specs[i], `"`):] < specs[j][strings.Index(specs[j], `"`):]
 		}
+	}
+	sort.Slice(std, byPath(std))
+	sort.Slice(others, byPath(others))
+
+	i := "\nimport (\n"
+	for _, spec := range std {
+		i += spec + "\n"
+	}
+	if len(std) > 0 && len(others) > 0 {
+		i += "\n"
+	}
+	for _, spec := range others {
+		i += spec + "\n"
+	}
+	return i + ")\n"
+}
+
+// useType records the imports needed by a field type
+func (s *generation) useType(valueType string) {
+	switch {
+	case strings.HasPrefix(valueType, "null."):
+		s.haveNull = true
+	case strings.HasPrefix(valueType, "json."):
+		s.haveJSON = true
+	case strings.HasPrefix(valueType, "pq."):
+		s.havePqArray = true
+	}
+}
+
+// columnGoType returns the go type of a column and the import spec of a mapped type,
+// the type is empty when the column type is unknown and not mapped or when the mapping is invalid
+func (g *Generator) columnGoType(table *Table, column *Column) (string, string, error) {
+	if mapped, ok := g.opts.Types.lookup(table.Name, column); ok {
+		return goType(mapped)
+	}
+	// If the guregu (https://github.com/guregu/null) CLI option is passed use its types, otherwise use go's sql.NullX
+	switch table.Driver {
+	case "postgres":
+		return postgresTypeToGoType(column.DataType, column.Nullable, g.opts.GureguTypes), "", nil
+	case "sqlite":
+		return sqliteTypeToGoType(column.DataType, column.Nullable, g.opts.GureguTypes), "", nil
+	}
+	return g.mysqlColumnGoType(column), "", nil
+}
+
+// Generate go struct entries for the columns of a table
+func (g *Generator) generateMysqlTypes(table *Table, s *generation) string {
+	structure := "struct {"
+	onUpdate := false
+	s.view = table.View
+	s.readOnlyHooks = table.View && g.opts.GormAnnotation == true
+	s.schema = table.Schema
+
+	for _, column := range table.Columns {
+		key := column.Name
 
 		primary := ""
-		if mysqlType["primary"] == "PRI" {
-			pk = key
+		if column.Key == "PRI" {
 			primary = ";primary_key"
 		}
 
-		if mysqlType["value"] == "timestamp" || mysqlType["value"] == "datetime" {
+		if isTimestampType(column.DataType) {
 			if strings.Contains(key, "create") {
-				createdAtKey = key
+				s.createdAtKey = key
+			}
+			if strings.Contains(key, "update") && !onUpdate {
+				s.updatedAtKey = key
 			}
-			if strings.Contains(key, "update") {
-				updatedATKey = key
+			// ON UPDATE CURRENT_TIMESTAMP wins over the name
+			if column.OnUpdateTimestamp() {
+				s.updatedAtKey = key
+				onUpdate = true
 			}
 		}
 
 		// Get the corresponding go value type for this mysql type
-		var valueType string
-		// If the guregu (https://github.com/guregu/null) CLI option is passed use its types, otherwise use go's sql.NullX
-
-		valueType = mysqlTypeToGoType(mysqlType["value"], nullable, gureguTypes)
+		valueType, spec, err := g.columnGoType(table, column)
+		if err == nil && valueType == "" {
+			// without fallback type Generate reports the column, the field stays untyped
+			s.unmapped = append(s.unmapped, unmappedColumn(table, column))
+			if g.opts.FallbackType != "" {
+				valueType, spec, err = goType(g.opts.FallbackType)
+			}
+		}
+		if err != nil && s.err == nil {
+			s.err = fmt.Errorf("column %s.%s: %w", table.Name, column.Name, err)
+		}
+		if spec != "" {
+			if s.imports == nil {
+				s.imports = make(map[string]string)
+			}
+			s.imports[valueType] = spec
+		}
+		s.useType(valueType)
 
 		fieldName := fmtFieldName(stringifyFirstChar(key))
+		s.addField(column.Name, fieldName, valueType)
+		if column.Key == "PRI" {
+			s.primaryKey = append(s.primaryKey, s.fields[strings.ToLower(column.Name)])
+		}
+		if value := goDefault(column, valueType); value != "" {
+			s.defaults = append(s.defaults, fieldName+": "+value)
+		}
+
+		// values assigned by the database must not be overwritten by the go zero values.
+		// jinzhu/gorm has no tag for ON UPDATE columns, the repository sets them as the updated at column
+		settings := primary
+		if column.AutoIncrement() {
+			settings += ";auto_increment"
+		} else if value := defaultTag(column, valueType); value != "" {
+			settings += ";default:" + value
+		}
+		settings += indexTags(table, column)
+
+		if column.Comment != "" {
+			structure += "\n" + docComment(column.Comment)
+		}
 		var annotations []string
-		if gormAnnotation == true {
-			annotations = append(annotations, fmt.Sprintf("gorm:\"column:%s%s\"", key, primary))
+		if g.opts.GormAnnotation == true {
+			annotations = append(annotations, fmt.Sprintf("gorm:\"column:%s%s\"", key, settings))
 		}
-		if jsonAnnotation == true {
+		if g.opts.JSONAnnotation == true {
 			//annotations = append(annotations, fmt.Sprintf("json:\"%s%s\"", key, primary))
 			annotations = append(annotations, fmt.Sprintf("json:\"%s\"", key))
 		}
@@ -154,65 +764,570 @@ func generateMysqlTypes(obj map[string]map[string]string, depth int, jsonAnnotat
 				valueType)
 		}
 	}
+	if len(s.primaryKey) == 1 {
+		s.pk = s.primaryKey[0].Column
+	}
+	s.addUniqueKeys(table)
+	if g.opts.GormAnnotation == true {
+		structure += g.generateAssociations(table, s)
+	}
 	return structure
 }
 
-// mysqlTypeToGoType converts the mysql types to go compatible sql.Nullable (https://golang.org/pkg/database/sql/) types
-func mysqlTypeToGoType(mysqlType string, nullable bool, gureguTypes bool) string {
-	switch mysqlType {
-	case "tinyint", "int", "smallint", "mediumint":
-		if nullable {
-			if gureguTypes {
-				haveNull = true
-				return gureguNullInt
+// generateAssociations adds a belongs to field for each foreign key of the table and a has many field
+// for each foreign key referencing it, the field types are the default struct names of the tables
+func (g *Generator) generateAssociations(table *Table, s *generation) string {
+	taken := make(map[string]bool)
+	for _, field := range s.fields {
+		taken[field.Field] = true
+	}
+
+	structure := ""
+	for _, fk := range table.ForeignKeys {
+		var fields []string
+		for _, column := range fk.Columns {
+			field, ok := s.fields[strings.ToLower(column)]
+			if !ok {
+				fields = nil
+				break
 			}
-			return sqlNullInt
+			fields = append(fields, field.Field)
+		}
+		if fields == nil {
+			continue
+		}
+		stem := g.structName(fk.RefTable)
+		name, jsonName := fkStem(fk.Columns[0])
+		if len(fk.Columns) > 1 || name == "" || taken[name] {
+			name, jsonName = stem, fk.RefTable
+		}
+		if taken[name] {
+			name, jsonName = stem+"By"+strings.Join(fields, "And"), fk.RefTable+"_by_"+strings.Join(fk.Columns, "_")
 		}
-		return golangInt
-	case "bigint":
-		if nullable {
-			if gureguTypes {
-				haveNull = true
-				return gureguNullInt
+		structure += g.association(s, taken, name, "*"+stem, jsonName, fields, fieldNames(fk.RefColumns))
+	}
+
+	joinTables := make(map[string]bool)
+	for _, relation := range table.ManyToMany {
+		joinTables[relation.JoinTable] = true
+	}
+	for _, fk := range table.ReferencedBy {
+		if joinTables[fk.Table] {
+			// the rows of a join table are reached through the many2many field
+			continue
+		}
+		stem := g.structName(fk.Table)
+		name, jsonName := stem, fk.Table
+		if taken[name] || countReferences(table.ReferencedBy, fk.Table) > 1 {
+			by, byJSON := fkStem(fk.Columns[0])
+			if len(fk.Columns) > 1 || by == "" {
+				by, byJSON = strings.Join(fieldNames(fk.Columns), "And"), strings.Join(fk.Columns, "_")
 			}
-			return sqlNullInt
+			name, jsonName = stem+"By"+by, fk.Table+"_by_"+byJSON
+		}
+		if taken[name] {
+			continue
+		}
+		structure += g.association(s, taken, name, "[]*"+stem, jsonName, fieldNames(fk.Columns), fieldNames(fk.RefColumns))
+	}
+
+	for _, relation := range table.ManyToMany {
+		stem := g.structName(relation.Ref.RefTable)
+		name, jsonName := stem, relation.Ref.RefTable
+		if taken[name] || relation.Ref.RefTable == table.Name {
+			name, jsonName = fmtFieldName(relation.JoinTable), relation.JoinTable
+		}
+		if taken[name] {
+			continue
+		}
+		taken[name] = true
+		field := tplAssociation{Field: name, Type: stem, Arg: argName(name)}
+		s.associations = append(s.associations, field)
+		if relation.Owner {
+			s.manyToMany = append(s.manyToMany, field)
 		}
-		return golangInt64
-	case "char", "enum", "varchar", "longtext", "mediumtext", "text", "tinytext":
-		if nullable {
-			if gureguTypes {
-				haveNull = true
-				return gureguNullString
+
+		// jinzhu/gorm reads the fields of both tables from foreignkey and association_foreignkey,
+		// and the columns of the join table from jointable_foreignkey and association_jointable_foreignkey
+		annotations := []string{fmt.Sprintf("gorm:\"many2many:%s;foreignkey:%s;association_foreignkey:%s;jointable_foreignkey:%s;association_jointable_foreignkey:%s\"",
+			qualifiedName(table.Schema, relation.JoinTable),
+			strings.Join(fieldNames(relation.Key.RefColumns), ","),
+			strings.Join(fieldNames(relation.Ref.RefColumns), ","),
+			strings.Join(relation.Key.Columns, ","),
+			strings.Join(relation.Ref.Columns, ","))}
+		if g.opts.JSONAnnotation == true {
+			annotations = append(annotations, fmt.Sprintf("json:\"%s,omitempty\"", jsonName))
+		}
+		structure += fmt.Sprintf("\n%s []*%s `%s`", name, stem, strings.Join(annotations, " "))
+	}
+	return structure
+}
+
+// association renders an association field and records it for the templates. jinzhu/gorm reads the fields
+// of the foreign key from foreignkey and the fields they reference from association_foreignkey
+func (g *Generator) association(s *generation, taken map[string]bool, name, valueType, jsonName string, foreignKey, references []string) string {
+	taken[name] = true
+	s.associations = append(s.associations, tplAssociation{Field: name})
+
+	annotations := []string{fmt.Sprintf("gorm:\"foreignkey:%s;association_foreignkey:%s\"", strings.Join(foreignKey, ","), strings.Join(references, ","))}
+	if g.opts.JSONAnnotation == true {
+		annotations = append(annotations, fmt.Sprintf("json:\"%s,omitempty\"", jsonName))
+	}
+	return fmt.Sprintf("\n%s %s `%s`", name, valueType, strings.Join(annotations, " "))
+}
+
+// fkStem returns the field and json names of the row a foreign key column points to,
+// e.g. User and user for user_id or userId, empty when the column has no id suffix
+func fkStem(column string) (string, string) {
+	jsonName := ""
+	switch {
+	case strings.HasSuffix(strings.ToLower(column), "_id"):
+		jsonName = column[:len(column)-3]
+	case strings.HasSuffix(column, "Id"), strings.HasSuffix(column, "ID"):
+		jsonName = column[:len(column)-2]
+	}
+	if jsonName == "" {
+		return "", ""
+	}
+	return fmtFieldName(stringifyFirstChar(jsonName)), jsonName
+}
+
+// fieldNames returns the go field names of the columns
+func fieldNames(columns []string) []string {
+	var names []string
+	for _, column := range columns {
+		names = append(names, fmtFieldName(stringifyFirstChar(column)))
+	}
+	return names
+}
+
+// countReferences counts the foreign keys of a table among fks
+func countReferences(fks []*ForeignKey, table string) int {
+	n := 0
+	for _, fk := range fks {
+		if fk.Table == table {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *generation) addField(column, fieldName, valueType string) {
+	if s.fields == nil {
+		s.fields = make(map[string]tplField)
+	}
+	s.fields[strings.ToLower(column)] = tplField{Column: column, Field: fieldName, Arg: argName(fieldName), Type: valueType}
+}
+
+// addUniqueKeys collects the unique indexes whose columns are all fields of the struct,
+// indexes on the same columns are rendered once
+func (s *generation) addUniqueKeys(table *Table) {
+	seen := make(map[string]bool)
+	for _, index := range table.Indexes {
+		if !index.Unique || index.Primary || len(index.Columns) == 0 {
+			continue
+		}
+		key := tplKey{}
+		var names []string
+		for _, column := range index.Columns {
+			field, ok := s.fields[strings.ToLower(column)]
+			if !ok {
+				key.Fields = nil
+				break
 			}
-			return sqlNullString
-		}
-		return "string"
-	case "date", "datetime", "time", "timestamp":
-		if nullable && gureguTypes {
-			haveNull = true
-			return gureguNullTime
-		}
-		return golangTime
-	case "decimal", "double":
-		if nullable {
-			if gureguTypes {
-				haveNull = true
-				return gureguNullFloat
+			key.Fields = append(key.Fields, field)
+			names = append(names, field.Field)
+		}
+		key.Name = strings.Join(names, "And")
+		if key.Fields == nil || seen[key.Name] {
+			continue
+		}
+		seen[key.Name] = true
+		s.uniqueKeys = append(s.uniqueKeys, key)
+	}
+}
+
+// argName returns the parameter name of a field in the generated methods, avoiding go keywords
+// and the names used by the repository templates
+func argName(fieldName string) string {
+	name := Lcfirst(fieldName)
+	if strings.ToUpper(fieldName) == fieldName {
+		// ID is id, not iD
+		name = strings.ToLower(fieldName)
+	}
+	switch name {
+	case "a", "c", "d", "q", "ret", "err", "set", "fields", "where", "data", "time", "errors", "gorm", "model":
+		return name + "Value"
+	}
+	if token.Lookup(name).IsKeyword() {
+		return name + "Value"
+	}
+	return name
+}
+
+// indexTags returns the jinzhu/gorm index and unique_index settings of the column, the primary key is tagged
+// primary_key instead. gorm reads one setting of each kind per field, so the names of the indexes are comma separated.
+// The indexes gorm would recreate differently get no tags, see UntaggedIndexes
+func indexTags(table *Table, column *Column) string {
+	var indexes, uniques []string
+	for _, index := range table.Indexes {
+		if index.Primary || index.Position(column.Name) < 0 || untaggedReason(table, index) != "" {
+			continue
+		}
+		if index.Unique {
+			uniques = append(uniques, index.Name)
+		} else {
+			indexes = append(indexes, index.Name)
+		}
+	}
+	var tags string
+	if len(indexes) > 0 {
+		tags += ";index:" + strings.Join(indexes, ",")
+	}
+	if len(uniques) > 0 {
+		tags += ";unique_index:" + strings.Join(uniques, ",")
+	}
+	return tags
+}
+
+// UntaggedIndex is an index the gorm tags leave out, Reason tells why
+type UntaggedIndex struct {
+	Table  string
+	Index  string
+	Reason string
+}
+
+func (i UntaggedIndex) String() string {
+	return fmt.Sprintf("%s.%s (%s)", i.Table, i.Index, i.Reason)
+}
+
+// UntaggedIndexes lists the indexes of the tables which get no gorm tags as AutoMigrate would build a different index:
+// gorm has no tag for fulltext, spatial and prefix length indexes, and orders the columns of composite indexes like the fields
+func (g *Generator) UntaggedIndexes(tables []*Table) []UntaggedIndex {
+	if g.opts.GormAnnotation != true {
+		return nil
+	}
+	var untagged []UntaggedIndex
+	for _, table := range tables {
+		for _, index := range table.Indexes {
+			if reason := untaggedReason(table, index); reason != "" && !index.Primary {
+				untagged = append(untagged, UntaggedIndex{Table: table.Name, Index: index.Name, Reason: reason})
 			}
-			return sqlNullFloat
-		}
-		return golangFloat64
-	case "float":
-		if nullable {
-			if gureguTypes {
-				haveNull = true
-				return gureguNullFloat
+		}
+	}
+	return untagged
+}
+
+// untaggedReason returns why the index cannot be written as gorm tags, empty when it can
+func untaggedReason(table *Table, index *Index) string {
+	switch index.Kind {
+	case "FULLTEXT", "SPATIAL":
+		return strings.ToLower(index.Kind) + " index"
+	}
+	for n := range index.Columns {
+		if index.Length(n) > 0 {
+			return "prefix length"
+		}
+	}
+	last := -1
+	for _, name := range index.Columns {
+		position := -1
+		for i, column := range table.Columns {
+			if strings.EqualFold(column.Name, name) {
+				position = i
 			}
-			return sqlNullFloat
 		}
-		return golangFloat32
-	case "binary", "blob", "longblob", "mediumblob", "varbinary":
-		return golangByteArray
+		if position < last {
+			return "columns " + strings.Join(index.Columns, ", ") + " not in table order"
+		}
+		last = position
 	}
 	return ""
 }
+
+// defaultTag returns the value of the gorm default tag, empty when the column has no default
+// or the default cannot be written in a struct tag
+func defaultTag(column *Column, valueType string) string {
+	if column.Default == nil || strings.ContainsAny(*column.Default, ";\"`\n") {
+		return ""
+	}
+	value := *column.Default
+	if !column.DefaultGenerated() && isStringType(valueType) {
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
+	}
+	return value
+}
+
+// goDefault returns the column default as a go expression of valueType,
+// empty when the default cannot be represented in go
+func goDefault(column *Column, valueType string) string {
+	if column.Default == nil {
+		return ""
+	}
+	value := *column.Default
+
+	if column.DefaultGenerated() {
+		if !isCurrentTimestamp(value) {
+			return ""
+		}
+		switch valueType {
+		case golangTime:
+			return "time.Now()"
+		case gureguNullTime:
+			return "null.TimeFrom(time.Now())"
+		}
+		return ""
+	}
+
+	// postgres and sqlite keep the TRUE and FALSE literals, which are 1 and 0 for the integer fields
+	number := value
+	switch strings.ToLower(value) {
+	case "true":
+		number = "1"
+	case "false":
+		number = "0"
+	}
+
+	switch valueType {
+	case "string":
+		return strconv.Quote(value)
+	case sqlNullString:
+		return fmt.Sprintf("sql.NullString{String: %s, Valid: true}", strconv.Quote(value))
+	case gureguNullString:
+		return fmt.Sprintf("null.StringFrom(%s)", strconv.Quote(value))
+	case golangInt, golangInt64:
+		if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+			return strconv.FormatInt(n, 10)
+		}
+	case golangUint8, golangUint16, golangUint32, golangUint64:
+		if n, err := strconv.ParseUint(number, 10, 64); err == nil {
+			return strconv.FormatUint(n, 10)
+		}
+	case sqlNullInt:
+		if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+			return fmt.Sprintf("sql.NullInt64{Int64: %d, Valid: true}", n)
+		}
+	case gureguNullInt:
+		if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+			return fmt.Sprintf("null.IntFrom(%d)", n)
+		}
+	case golangFloat32, golangFloat64:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	case sqlNullFloat:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return fmt.Sprintf("sql.NullFloat64{Float64: %s, Valid: true}", strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	case gureguNullFloat:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return fmt.Sprintf("null.FloatFrom(%s)", strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	case golangBool:
+		if b, ok := parseBoolDefault(value); ok {
+			return strconv.FormatBool(b)
+		}
+	case sqlNullBool:
+		if b, ok := parseBoolDefault(value); ok {
+			return fmt.Sprintf("sql.NullBool{Bool: %t, Valid: true}", b)
+		}
+	case gureguNullBool:
+		if b, ok := parseBoolDefault(value); ok {
+			return fmt.Sprintf("null.BoolFrom(%t)", b)
+		}
+	}
+	return ""
+}
+
+// parseBoolDefault reads the defaults of bool columns: 0, 1, b'0', b'1', true and false
+func parseBoolDefault(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "1", "b'1'", "true":
+		return true, true
+	case "0", "b'0'", "false":
+		return false, true
+	}
+	return false, false
+}
+
+func isStringType(valueType string) bool {
+	return valueType == "string" || valueType == sqlNullString || valueType == gureguNullString
+}
+
+// constructor renders New<Struct>, which returns the struct filled with the column defaults
+func (s *generation) constructor(structName string) string {
+	c := fmt.Sprintf("\n// New%s returns a new %s with the column defaults\nfunc New%s() *%s {\nreturn &%s{",
+		structName, structName, structName, structName, structName)
+	for _, value := range s.defaults {
+		c += "\n" + value + ","
+	}
+	if len(s.defaults) > 0 {
+		c += "\n"
+	}
+	return c + "}\n}\n"
+}
+
+// pkType returns the go type of a single column primary key, empty for other keys
+func (s *generation) pkType() string {
+	if len(s.primaryKey) != 1 {
+		return ""
+	}
+	return s.primaryKey[0].Type
+}
+
+// keyMethod returns the name of the method returning the composite primary key: Key,
+// or PrimaryKey when a column or association field is named Key, or RowKey when both are taken
+func (s *generation) keyMethod() string {
+	taken := make(map[string]bool)
+	for _, field := range s.fields {
+		taken[field.Field] = true
+	}
+	for _, association := range s.associations {
+		taken[association.Field] = true
+	}
+	for _, name := range []string{"Key", "PrimaryKey"} {
+		if !taken[name] {
+			return name
+		}
+	}
+	return "RowKey"
+}
+
+// keyType renders the <Struct>Key type of a composite primary key and the method returning it, see keyMethod,
+// nothing for single column keys
+func (s *generation) keyType(structName string) string {
+	if len(s.primaryKey) < 2 {
+		return ""
+	}
+	method := s.keyMethod()
+	t := fmt.Sprintf("\n// %sKey is the primary key of %s\ntype %sKey struct {", structName, structName, structName)
+	m := fmt.Sprintf("\n// %s returns the primary key of the row\nfunc (m *%s) %s() %sKey {\nreturn %sKey{", method, structName, method, structName, structName)
+	for _, field := range s.primaryKey {
+		t += fmt.Sprintf("\n%s %s", field.Field, field.Type)
+		m += fmt.Sprintf("\n%s: m.%s,", field.Field, field.Field)
+	}
+	return t + "\n}\n" + m + "\n}\n}\n"
+}
+
+// readOnly renders the gorm hooks of a view model, jinzhu/gorm has no read-only tag
+// but aborts Create, Save, Update and Delete when the BeforeSave or BeforeDelete hook fails
+func (s *generation) readOnly(structName, tableName string) string {
+	if !s.readOnlyHooks {
+		return ""
+	}
+	h := ""
+	for _, hook := range [][2]string{{"BeforeSave", "write to"}, {"BeforeDelete", "delete from"}} {
+		h += fmt.Sprintf("\n// %s refuses to %s the %s view\nfunc (m *%s) %s() error {\nreturn errors.New(%q)\n}\n",
+			hook[0], hook[1], tableName, structName, hook[0], tableName+" is a read-only view")
+	}
+	return h
+}
+
+// docComment renders a table or column comment as go comment lines, without the trailing newline
+func docComment(comment string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(comment), "\n") {
+		lines = append(lines, strings.TrimRight("// "+strings.TrimSpace(line), " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isTimestampType reports whether the column can hold a created/updated time
+func isTimestampType(dbType string) bool {
+	switch strings.ToLower(dbType) {
+	case "timestamp", "datetime", "timestamptz":
+		return true
+	}
+	return false
+}
+
+// mysqlColumnGoType refines the DATA_TYPE mapping with the COLUMN_TYPE, e.g. int(10) unsigned or tinyint(1)
+func (g *Generator) mysqlColumnGoType(column *Column) string {
+	columnType := strings.ToLower(column.ColumnType)
+
+	if g.opts.TinyintAsBool && strings.HasPrefix(columnType, "tinyint(1)") {
+		if column.Nullable {
+			if g.opts.GureguTypes {
+				return gureguNullBool
+			}
+			return sqlNullBool
+		}
+		return golangBool
+	}
+
+	if g.opts.UnsignedTypes && strings.Contains(columnType, "unsigned") {
+		var unsigned string
+		switch strings.ToLower(column.DataType) {
+		case "tinyint":
+			unsigned = golangUint8
+		case "smallint":
+			unsigned = golangUint16
+		case "mediumint", "int":
+			unsigned = golangUint32
+		case "bigint":
+			unsigned = golangUint64
+		}
+		switch {
+		case unsigned == "":
+		case !column.Nullable:
+			return unsigned
+		case unsigned == golangUint64:
+			// bigint unsigned overflows sql.NullInt64 and null.Int, a pointer scans NULL as nil
+			return golangUint64Ptr
+		case g.opts.GureguTypes:
+			return gureguNullInt
+		default:
+			return sqlNullInt
+		}
+	}
+
+	return mysqlTypeToGoType(column.DataType, column.Nullable, g.opts.GureguTypes)
+}
+
+// mysqlTypes are the default go types of the mysql DATA_TYPEs: not null, nullable and nullable with guregu.
+// Options.Types override them
+var mysqlTypes = map[string][3]string{
+	"tinyint":    {golangInt, sqlNullInt, gureguNullInt},
+	"int":        {golangInt, sqlNullInt, gureguNullInt},
+	"smallint":   {golangInt, sqlNullInt, gureguNullInt},
+	"mediumint":  {golangInt, sqlNullInt, gureguNullInt},
+	"bigint":     {golangInt64, sqlNullInt, gureguNullInt},
+	"char":       {"string", sqlNullString, gureguNullString},
+	"enum":       {"string", sqlNullString, gureguNullString},
+	"varchar":    {"string", sqlNullString, gureguNullString},
+	"longtext":   {"string", sqlNullString, gureguNullString},
+	"mediumtext": {"string", sqlNullString, gureguNullString},
+	"text":       {"string", sqlNullString, gureguNullString},
+	"tinytext":   {"string", sqlNullString, gureguNullString},
+	"date":       {golangTime, golangTime, gureguNullTime},
+	"datetime":   {golangTime, golangTime, gureguNullTime},
+	"time":       {golangTime, golangTime, gureguNullTime},
+	"timestamp":  {golangTime, golangTime, gureguNullTime},
+	"decimal":    {golangFloat64, sqlNullFloat, gureguNullFloat},
+	"double":     {golangFloat64, sqlNullFloat, gureguNullFloat},
+	"float":      {golangFloat32, sqlNullFloat, gureguNullFloat},
+	"binary":     {golangByteArray, golangByteArray, golangByteArray},
+	"bit":        {golangByteArray, golangByteArray, golangByteArray},
+	"json":       {golangJSON, golangJSON, golangJSON},
+	"year":       {golangInt, sqlNullInt, gureguNullInt},
+	"set":        {"string", sqlNullString, gureguNullString},
+	"blob":       {golangByteArray, golangByteArray, golangByteArray},
+	"longblob":   {golangByteArray, golangByteArray, golangByteArray},
+	"mediumblob": {golangByteArray, golangByteArray, golangByteArray},
+	"tinyblob":   {golangByteArray, golangByteArray, golangByteArray},
+	"varbinary":  {golangByteArray, golangByteArray, golangByteArray},
+}
+
+// mysqlTypeToGoType converts the mysql types to go compatible sql.Nullable (https://golang.org/pkg/database/sql/) types
+func mysqlTypeToGoType(mysqlType string, nullable bool, gureguTypes bool) string {
+	types, ok := mysqlTypes[mysqlType]
+	switch {
+	case !ok:
+		return ""
+	case !nullable:
+		return types[0]
+	case gureguTypes:
+		return types[2]
+	}
+	return types[1]
+}
@@ -1,9 +1,11 @@
 package db2struct
 
 import (
+	"database/sql"
 	"testing"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql" // Initialize mysql driver
+	"github.com/go-sql-driver/mysql"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -19,7 +21,7 @@ func TestGetColumnsFromMysqlTable(t *testing.T) {
 	Convey("Should be able to connect to test database and create columnMap", t, func() {
 		So(err, ShouldBeNil)
 		So(columMap, ShouldNotBeNil)
-		So(*columMap, ShouldNotBeEmpty)
+		So(columMap.Columns, ShouldNotBeEmpty)
 	})
 
 	columMap, err = GetColumnsFromMysqlTable(testMariadbUsername, testMariadbPassword, "doesnotexists", testMariadbPort, testMariadbDatabase, testTable)
@@ -28,3 +30,88 @@ func TestGetColumnsFromMysqlTable(t *testing.T) {
 		So(columMap, ShouldBeNil)
 	})
 }
+
+func TestMysqlDefault(t *testing.T) {
+	Convey("Should read mysql and mariadb defaults", t, func() {
+		value, extra := mysqlDefault(sql.NullString{String: "new", Valid: true}, "")
+		So(*value, ShouldEqual, "new")
+		So(extra, ShouldEqual, "")
+
+		value, extra = mysqlDefault(sql.NullString{String: "CURRENT_TIMESTAMP", Valid: true}, "DEFAULT_GENERATED on update CURRENT_TIMESTAMP")
+		So(*value, ShouldEqual, "CURRENT_TIMESTAMP")
+		So(extra, ShouldEqual, "DEFAULT_GENERATED on update CURRENT_TIMESTAMP")
+
+		// mysql 5.7
+		value, extra = mysqlDefault(sql.NullString{String: "CURRENT_TIMESTAMP", Valid: true}, "")
+		So(*value, ShouldEqual, "CURRENT_TIMESTAMP")
+		So(extra, ShouldEqual, "DEFAULT_GENERATED")
+
+		// mariadb
+		value, extra = mysqlDefault(sql.NullString{String: "'it''s'", Valid: true}, "")
+		So(*value, ShouldEqual, "it's")
+		So(extra, ShouldEqual, "")
+		value, extra = mysqlDefault(sql.NullString{String: "current_timestamp()", Valid: true}, "on update current_timestamp()")
+		So(*value, ShouldEqual, "CURRENT_TIMESTAMP")
+		So(extra, ShouldEqual, "DEFAULT_GENERATED on update current_timestamp()")
+		value, _ = mysqlDefault(sql.NullString{String: "NULL", Valid: true}, "")
+		So(value, ShouldBeNil)
+
+		value, _ = mysqlDefault(sql.NullString{}, "")
+		So(value, ShouldBeNil)
+	})
+}
+
+func TestMysqlConfig(t *testing.T) {
+	Convey("Should build the dsn with the driver", t, func() {
+		source := &MysqlSource{User: "root", Password: "p@ss/word", Host: "db", Port: 3307, Database: "shop"}
+		cfg, err := source.config()
+		So(err, ShouldBeNil)
+		parsed, err := mysql.ParseDSN(cfg.FormatDSN())
+		So(err, ShouldBeNil)
+		So(parsed.Passwd, ShouldEqual, "p@ss/word")
+		So(parsed.Addr, ShouldEqual, "db:3307")
+		So(parsed.DBName, ShouldEqual, "shop")
+		So(parsed.ParseTime, ShouldBeTrue)
+		So(parsed.Timeout, ShouldEqual, 10*time.Second)
+	})
+
+	Convey("Should connect through a socket", t, func() {
+		cfg, err := (&MysqlSource{User: "root", Socket: "/run/mysqld/mysqld.sock", Database: "shop"}).config()
+		So(err, ShouldBeNil)
+		So(cfg.Net, ShouldEqual, "unix")
+		So(cfg.Addr, ShouldEqual, "/run/mysqld/mysqld.sock")
+	})
+
+	Convey("Should use a dsn, the database and timeouts overriding it", t, func() {
+		source := &MysqlSource{DSN: "app:secret@tcp(db:3306)/shop?charset=utf8mb4&timeout=3s", Database: "reports", ReadTimeout: time.Minute}
+		cfg, err := source.config()
+		So(err, ShouldBeNil)
+		So(cfg.User, ShouldEqual, "app")
+		So(cfg.DBName, ShouldEqual, "reports")
+		So(cfg.Params["charset"], ShouldEqual, "utf8mb4")
+		So(cfg.Timeout, ShouldEqual, 3*time.Second)
+		So(cfg.ReadTimeout, ShouldEqual, time.Minute)
+
+		_, err = (&MysqlSource{DSN: "app:secret@db:3306/shop"}).config()
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Should check the tls options", t, func() {
+		cfg, err := (&MysqlSource{TLS: "skip-verify"}).config()
+		So(err, ShouldBeNil)
+		So(cfg.TLSConfig, ShouldEqual, "skip-verify")
+
+		_, err = (&MysqlSource{TLS: "preferred"}).config()
+		So(err, ShouldNotBeNil)
+		_, err = (&MysqlSource{TLSCA: "tests/mariadb.sql"}).config()
+		So(err.Error(), ShouldEqual, "no PEM certificate found in tests/mariadb.sql")
+		_, err = (&MysqlSource{TLS: "false", TLSCA: "tests/mariadb.sql"}).config()
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Should fail fast when the server cannot be reached", t, func() {
+		_, err := (&MysqlSource{User: "root", Socket: "/nonexistent/mysqld.sock", Database: "shop"}).Tables()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldStartWith, "cannot connect to mysql server unix(/nonexistent/mysqld.sock) as root:")
+	})
+}
@@ -0,0 +1,451 @@
+package db2struct
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SqliteSource reads tables from the PRAGMA statements of a sqlite file,
+// all calls share one connection pool which is opened on first use
+type SqliteSource struct {
+	File string
+
+	pool sharedPool
+}
+
+// Table implements SchemaSource
+func (s *SqliteSource) Table(name string) (*Table, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	return getSqliteTable(db, s.File, name)
+}
+
+// Tables implements SchemaSource
+func (s *SqliteSource) Tables() ([]string, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	return getSqliteTableNames(db)
+}
+
+// tables reads the foreign keys of the whole file once, instead of once per table,
+// and then the columns and indexes of every table
+func (s *SqliteSource) tables(names []string) ([]*Table, []error) {
+	db, err := s.open()
+	if err != nil {
+		return failTables(len(names), err)
+	}
+	fks, err := getSqliteForeignKeys(db)
+	if err != nil {
+		return failTables(len(names), err)
+	}
+	tables := make([]*Table, len(names))
+	errs := make([]error, len(names))
+	for i, name := range names {
+		tables[i], errs[i] = readSqliteTable(db, s.File, name, fks)
+	}
+	return tables, errs
+}
+
+// Close closes the connection pool
+func (s *SqliteSource) Close() error {
+	return s.pool.close()
+}
+
+func (s *SqliteSource) open() (*sql.DB, error) {
+	return s.pool.open(func() (*sql.DB, error) {
+		return openSqlite(s.File)
+	})
+}
+
+func openSqlite(sqliteFile string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+sqliteFile+"?mode=ro")
+	// Check for error in db, note this does not check the file but does check uri
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// GetTablesFromSqliteFile Select the tables of a sqlite file from sqlite_master, sorted by name
+func GetTablesFromSqliteFile(sqliteFile string) ([]string, error) {
+	db, err := openSqlite(sqliteFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return getSqliteTableNames(db)
+}
+
+func getSqliteTableNames(db *sql.DB) ([]string, error) {
+	tableQuery := "SELECT name FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%' ORDER BY name ASC"
+
+	if Debug {
+		fmt.Println("running: " + tableQuery)
+	}
+
+	return queryStrings(db, tableQuery)
+}
+
+// GetColumnsFromSqliteTable Select column details from the PRAGMA statements of a sqlite file and return the table
+func GetColumnsFromSqliteTable(sqliteFile string, sqliteTable string) (*Table, error) {
+
+	db, err := openSqlite(sqliteFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return getSqliteTable(db, sqliteFile, sqliteTable)
+}
+
+func getSqliteTable(db *sql.DB, sqliteFile string, sqliteTable string) (*Table, error) {
+	// the foreign keys referencing the table are declared by the other tables
+	fks, err := getSqliteForeignKeys(db)
+	if err != nil {
+		return nil, err
+	}
+	return readSqliteTable(db, sqliteFile, sqliteTable, fks)
+}
+
+// readSqliteTable reads the columns and indexes of the table, fks are the foreign keys of every table of the file
+func readSqliteTable(db *sql.DB, sqliteFile string, sqliteTable string, fks []*ForeignKey) (*Table, error) {
+	keys, indexes, err := getSqliteIndexes(db, sqliteTable)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &Table{Name: sqliteTable, Driver: "sqlite"}
+	columnDataTypeQuery := "PRAGMA table_info(" + quoteSqliteIdentifier(sqliteTable) + ")"
+
+	if Debug {
+		fmt.Println("running: " + columnDataTypeQuery)
+	}
+
+	rows, err := db.Query(columnDataTypeQuery)
+	if err != nil {
+		return nil, fmt.Errorf("reading the columns of %s: %w", sqliteTable, err)
+	}
+	defer rows.Close()
+
+	// an INTEGER PRIMARY KEY of a single column is an alias of the rowid and is assigned by sqlite
+	var rowid []int
+	// the primary key columns by their position in the key
+	primaryKey := make(map[int]string)
+	for rows.Next() {
+		var cid, notNull, primary int
+		var column, dataType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &column, &dataType, &notNull, &defaultValue, &primary); err != nil {
+			return nil, fmt.Errorf("reading column %s.%s: %w", sqliteTable, column, err)
+		}
+
+		if primary > 0 {
+			keys[column] = "PRI"
+			primaryKey[primary] = column
+		}
+
+		value, generated := sqlDefault(defaultValue.String)
+		extra := ""
+		if generated {
+			extra = "DEFAULT_GENERATED"
+		}
+		if primary > 0 && strings.EqualFold(dataType, "INTEGER") {
+			rowid = append(rowid, len(table.Columns))
+		}
+
+		table.Columns = append(table.Columns, &Column{
+			Name:       column,
+			DataType:   dataType,
+			ColumnType: dataType,
+			// sqlite reports INTEGER PRIMARY KEY (the rowid alias) as nullable, it never is
+			Nullable: notNull == 0 && primary == 0,
+			Key:      keys[column],
+			Default:  value,
+			Extra:    extra,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading the columns of %s: %w", sqliteTable, err)
+	}
+
+	if len(table.Columns) == 0 {
+		return nil, fmt.Errorf("table %s not found in %s", sqliteTable, sqliteFile)
+	}
+	if len(rowid) == 1 && len(table.PrimaryKey()) == 1 {
+		table.Columns[rowid[0]].Extra = "auto_increment"
+	}
+	if len(primaryKey) > 0 {
+		index := &Index{Name: "PRIMARY", Primary: true, Unique: true}
+		for i := 1; i <= len(primaryKey); i++ {
+			index.Columns = append(index.Columns, primaryKey[i])
+		}
+		table.Indexes = append(table.Indexes, index)
+	}
+	table.Indexes = append(table.Indexes, indexes...)
+
+	var tableType string
+	if err := db.QueryRow("SELECT type FROM sqlite_master WHERE name = ?", sqliteTable).Scan(&tableType); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("reading the type of %s: %w", sqliteTable, err)
+	}
+	table.View = tableType == "view"
+
+	for _, fk := range fks {
+		if strings.EqualFold(fk.Table, table.Name) {
+			table.ForeignKeys = append(table.ForeignKeys, fk)
+		}
+		if strings.EqualFold(fk.RefTable, table.Name) {
+			table.ReferencedBy = append(table.ReferencedBy, fk)
+		}
+	}
+
+	return table, rows.Err()
+}
+
+// getSqliteIndexes reads index_list and foreign_key_list and returns the indexes other than the primary key,
+// sorted by name, and the mysql style COLUMN_KEY (UNI or MUL) of every indexed column
+func getSqliteIndexes(db *sql.DB, sqliteTable string) (map[string]string, []*Index, error) {
+	keys := make(map[string]string)
+
+	rows, err := db.Query("PRAGMA index_list(" + quoteSqliteIdentifier(sqliteTable) + ")")
+	if err != nil {
+		return nil, nil, err
+	}
+	var indexes []string
+	var uniques []bool
+	for rows.Next() {
+		var seq, unique, partial int
+		var name, origin string
+		if err := rows.Scan(&seq, &name, &unique, &origin, &partial); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("reading the indexes of table %s: %w", sqliteTable, err)
+		}
+		// the primary key is reported by table_info
+		if origin == "pk" {
+			continue
+		}
+		indexes = append(indexes, name)
+		uniques = append(uniques, unique == 1)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading the indexes of table %s: %w", sqliteTable, err)
+	}
+
+	var tableIndexes []*Index
+	for i, index := range indexes {
+		rows, err := db.Query("PRAGMA index_info(" + quoteSqliteIdentifier(index) + ")")
+		if err != nil {
+			return nil, nil, err
+		}
+		var columns []string
+		for rows.Next() {
+			var seqno, cid int
+			var name sql.NullString
+			if err := rows.Scan(&seqno, &cid, &name); err != nil {
+				rows.Close()
+				return nil, nil, fmt.Errorf("reading index %s of table %s: %w", index, sqliteTable, err)
+			}
+			// expression indexes have no column name
+			columns = append(columns, name.String)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, fmt.Errorf("reading index %s of table %s: %w", index, sqliteTable, err)
+		}
+
+		tableIndex := &Index{Name: index, Unique: uniques[i]}
+		if uniques[i] {
+			tableIndex.Kind = "UNIQUE"
+		}
+		for _, column := range columns {
+			if column != "" {
+				tableIndex.Columns = append(tableIndex.Columns, column)
+			}
+		}
+		if len(tableIndex.Columns) > 0 {
+			tableIndexes = append(tableIndexes, tableIndex)
+		}
+
+		if len(columns) == 0 || columns[0] == "" {
+			continue
+		}
+		if uniques[i] && len(columns) == 1 {
+			keys[columns[0]] = "UNI"
+		} else if keys[columns[0]] == "" {
+			keys[columns[0]] = "MUL"
+		}
+	}
+
+	sort.Slice(tableIndexes, func(i, j int) bool {
+		return tableIndexes[i].Name < tableIndexes[j].Name
+	})
+
+	rows, err = db.Query("PRAGMA foreign_key_list(" + quoteSqliteIdentifier(sqliteTable) + ")")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, seq int
+		var table, from string
+		var to sql.NullString
+		var onUpdate, onDelete, match string
+		if err := rows.Scan(&id, &seq, &table, &from, &to, &onUpdate, &onDelete, &match); err != nil {
+			return nil, nil, fmt.Errorf("reading the foreign keys of table %s: %w", sqliteTable, err)
+		}
+		// like mysql, the first column of a foreign key is reported as a multiple key
+		if seq == 0 && keys[from] == "" {
+			keys[from] = "MUL"
+		}
+	}
+
+	return keys, tableIndexes, rows.Err()
+}
+
+// getSqliteForeignKeys reads the foreign keys of every table of the file
+func getSqliteForeignKeys(db *sql.DB) ([]*ForeignKey, error) {
+	names, err := getSqliteTableNames(db)
+	if err != nil {
+		return nil, err
+	}
+	var fks []*ForeignKey
+	for _, name := range names {
+		tableFks, err := getSqliteTableForeignKeys(db, name)
+		if err != nil {
+			return nil, err
+		}
+		fks = append(fks, tableFks...)
+	}
+	return fks, nil
+}
+
+// getSqliteTableForeignKeys reads foreign_key_list, a foreign key without columns references the primary key
+func getSqliteTableForeignKeys(db *sql.DB, sqliteTable string) ([]*ForeignKey, error) {
+	rows, err := db.Query("PRAGMA foreign_key_list(" + quoteSqliteIdentifier(sqliteTable) + ")")
+	if err != nil {
+		return nil, err
+	}
+	var fks []*ForeignKey
+	lastID := -1
+	for rows.Next() {
+		var id, seq int
+		var refTable, from string
+		var to sql.NullString
+		var onUpdate, onDelete, match string
+		if err := rows.Scan(&id, &seq, &refTable, &from, &to, &onUpdate, &onDelete, &match); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("reading the foreign keys of table %s: %w", sqliteTable, err)
+		}
+
+		if id != lastID {
+			fks = append(fks, &ForeignKey{Table: sqliteTable, RefTable: refTable})
+			lastID = id
+		}
+		fk := fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, from)
+		if to.Valid {
+			fk.RefColumns = append(fk.RefColumns, to.String)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, fk := range fks {
+		if len(fk.RefColumns) > 0 {
+			continue
+		}
+		primaryKey, err := queryStrings(db, "SELECT name FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk", fk.RefTable)
+		if err != nil {
+			return nil, err
+		}
+		fk.RefColumns = primaryKey
+	}
+	return fks, nil
+}
+
+func quoteSqliteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// sqliteAffinity returns the type affinity of a declared column type,
+// following the rules of https://www.sqlite.org/datatype3.html#determination_of_column_affinity
+func sqliteAffinity(declaredType string) string {
+	t := strings.ToUpper(declaredType)
+	switch {
+	case strings.Contains(t, "INT"):
+		return "INTEGER"
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return "TEXT"
+	case strings.Contains(t, "BLOB"), t == "":
+		return "BLOB"
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return "REAL"
+	}
+	return "NUMERIC"
+}
+
+// sqliteTypeToGoType converts the declared sqlite types to go compatible types using their affinity
+func sqliteTypeToGoType(declaredType string, nullable bool, gureguTypes bool) string {
+	switch sqliteAffinity(declaredType) {
+	case "INTEGER":
+		if nullable {
+			if gureguTypes {
+				return gureguNullInt
+			}
+			return sqlNullInt
+		}
+		return golangInt64
+	case "TEXT":
+		if nullable {
+			if gureguTypes {
+				return gureguNullString
+			}
+			return sqlNullString
+		}
+		return "string"
+	case "BLOB":
+		return golangByteArray
+	case "REAL":
+		if nullable {
+			if gureguTypes {
+				return gureguNullFloat
+			}
+			return sqlNullFloat
+		}
+		return golangFloat64
+	}
+
+	// NUMERIC affinity, the sqlite3 driver converts date and boolean declarations itself
+	t := strings.ToLower(declaredType)
+	switch {
+	case strings.HasPrefix(t, "date"), strings.HasPrefix(t, "timestamp"):
+		if nullable && gureguTypes {
+			return gureguNullTime
+		}
+		return golangTime
+	case strings.HasPrefix(t, "bool"):
+		if nullable {
+			if gureguTypes {
+				return gureguNullBool
+			}
+			return sqlNullBool
+		}
+		return golangBool
+	}
+	if nullable {
+		if gureguTypes {
+			return gureguNullFloat
+		}
+		return sqlNullFloat
+	}
+	return golangFloat64
+}
@@ -0,0 +1,107 @@
+package db2struct
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3" // Initialize sqlite driver
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGetColumnsFromSqliteTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "db2struct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "test.db")
+	db, err := sql.Open("sqlite3", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(32) NOT NULL);
+CREATE TABLE orders (
+	id INTEGER PRIMARY KEY,
+	order_no TEXT NOT NULL UNIQUE,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	amount DECIMAL(10,2),
+	paid BOOLEAN NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL,
+	payload BLOB
+);
+CREATE VIEW paid_orders AS SELECT id, order_no, amount FROM orders WHERE paid = 1;`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columMap, err := GetColumnsFromSqliteTable(file, "orders")
+	Convey("Should be able to read the sqlite table", t, func() {
+		So(err, ShouldBeNil)
+		So(columMap, ShouldNotBeNil)
+		So(columMap.Driver, ShouldEqual, "sqlite")
+		So(*columMap.Column("id"), ShouldResemble, Column{Name: "id", DataType: "INTEGER", ColumnType: "INTEGER", Nullable: false, Key: "PRI", Extra: "auto_increment"})
+		So(columMap.Column("order_no").Key, ShouldEqual, "UNI")
+		So(columMap.Column("user_id").Key, ShouldEqual, "MUL")
+		So(columMap.Column("amount").Nullable, ShouldBeTrue)
+		So(*columMap.Column("paid").Default, ShouldEqual, "0")
+		So(columMap.Indexes[0], ShouldResemble, &Index{Name: "PRIMARY", Primary: true, Unique: true, Columns: []string{"id"}})
+		So(columMap.Indexes[1], ShouldResemble, &Index{Name: "sqlite_autoindex_orders_1", Unique: true, Kind: "UNIQUE", Columns: []string{"order_no"}})
+		So(columMap.Column("created_at").Default, ShouldBeNil)
+		So(columMap.View, ShouldBeFalse)
+	})
+
+	columMap, err = GetColumnsFromSqliteTable(file, "paid_orders")
+	Convey("Should read the columns of a view", t, func() {
+		So(err, ShouldBeNil)
+		So(columMap.View, ShouldBeTrue)
+		So(len(columMap.Columns), ShouldEqual, 3)
+		names, err := GetTablesFromSqliteFile(file)
+		So(err, ShouldBeNil)
+		So(names, ShouldResemble, []string{"orders", "paid_orders", "users"})
+	})
+
+	source := &SqliteSource{File: file}
+	tables, errs := LoadTables(source, []string{"users", "orders", "doesnotexists"}, 2)
+	Convey("Should read several tables through one shared connection pool", t, func() {
+		So(errs[0], ShouldBeNil)
+		So(tables[0].Column("name"), ShouldNotBeNil)
+		So(errs[1], ShouldBeNil)
+		So(tables[1].Column("order_no").Key, ShouldEqual, "UNI")
+		So(errs[2], ShouldNotBeNil)
+		So(source.Close(), ShouldBeNil)
+	})
+
+	Convey("Should read the foreign keys of the file once for all the tables", t, func() {
+		var bulk interface{} = source
+		_, ok := bulk.(bulkSource)
+		So(ok, ShouldBeTrue)
+		So(len(tables[0].ReferencedBy), ShouldEqual, 1)
+		So(tables[0].ReferencedBy[0], ShouldEqual, tables[1].ForeignKeys[0])
+		So(*tables[1].ForeignKeys[0], ShouldResemble, ForeignKey{Table: "orders", Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}})
+	})
+
+	columMap, err = GetColumnsFromSqliteTable(file, "doesnotexists")
+	Convey("Should get an error for an unknown table", t, func() {
+		So(err, ShouldNotBeNil)
+		So(columMap, ShouldBeNil)
+	})
+}
+
+func TestSqliteTypeToGoType(t *testing.T) {
+	Convey("Should use the sqlite type affinity", t, func() {
+		So(sqliteTypeToGoType("INTEGER", false, false), ShouldEqual, golangInt64)
+		So(sqliteTypeToGoType("UNSIGNED BIG INT", true, false), ShouldEqual, sqlNullInt)
+		So(sqliteTypeToGoType("VARCHAR(32)", false, false), ShouldEqual, "string")
+		So(sqliteTypeToGoType("CLOB", true, true), ShouldEqual, gureguNullString)
+		So(sqliteTypeToGoType("", false, false), ShouldEqual, golangByteArray)
+		So(sqliteTypeToGoType("DOUBLE PRECISION", false, false), ShouldEqual, golangFloat64)
+		So(sqliteTypeToGoType("DECIMAL(10,2)", true, false), ShouldEqual, sqlNullFloat)
+		So(sqliteTypeToGoType("DATETIME", false, false), ShouldEqual, golangTime)
+		So(sqliteTypeToGoType("BOOLEAN", false, false), ShouldEqual, golangBool)
+	})
+}
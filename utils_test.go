@@ -1,11 +1,51 @@
 package db2struct
 
 import (
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
 
+// rendered returns a rendered template, the errors fail the convey block
+func rendered(src string, err error) string {
+	if err != nil {
+		panic(err)
+	}
+	return src
+}
+
+// testTable builds a table from a column map, with the columns sorted by name
+func testTable(columnMap map[string]map[string]string) *Table {
+	table := &Table{Name: "test_table"}
+	for name, column := range columnMap {
+		table.Columns = append(table.Columns, &Column{
+			Name:       name,
+			DataType:   column["value"],
+			ColumnType: column["value"],
+			Nullable:   column["nullable"] == "YES",
+		})
+	}
+	sort.Slice(table.Columns, func(i, j int) bool {
+		return table.Columns[i].Name < table.Columns[j].Name
+	})
+	return table
+}
+
+// generateTestStruct renders the struct of the table as it is written to the model file
+func generateTestStruct(table *Table, jsonAnnotation bool, gormAnnotation bool, gureguTypes bool) ([]byte, error) {
+	g := NewGenerator(Options{JSONAnnotation: jsonAnnotation, GormAnnotation: gormAnnotation, GureguTypes: gureguTypes})
+	src := fmt.Sprintf("package test\n\ntype testStruct %s}", g.generateMysqlTypes(table, &generation{}))
+	return format.Source([]byte(src))
+}
+
 func TestLintFieldName(t *testing.T) {
 	name := lintFieldName("_")
 	Convey("Should get underscore as fieldName", t, func() {
@@ -52,7 +92,7 @@ type testStruct struct {
 		"stringColumn":     {"nullable": "NO", "value": "varchar"},
 		"nullStringColumn": {"nullable": "YES", "value": "varchar"},
 	}
-	bytes, err := Generate(columnMap, "test_table", "testStruct", "test", false, false, false)
+	bytes, err := generateTestStruct(testTable(columnMap), false, false, false)
 
 	Convey("Should be able to generate map from string column", t, func() {
 		So(err, ShouldBeNil)
@@ -90,7 +130,7 @@ type testStruct struct {
 		"varbinaryColumn":      {"nullable": "NO", "value": "varbinary"},
 		"nullVarbinaryColumn":  {"nullable": "YES", "value": "varbinary"},
 	}
-	bytes, err := Generate(columnMap, "test_table", "testStruct", "test", false, false, false)
+	bytes, err := generateTestStruct(testTable(columnMap), false, false, false)
 
 	Convey("Should be able to generate map from string column", t, func() {
 		So(err, ShouldBeNil)
@@ -125,7 +165,7 @@ type testStruct struct {
 }
 `
 
-	bytes, err := Generate(columnMap, "test_table", "testStruct", "test", false, false, false)
+	bytes, err := generateTestStruct(testTable(columnMap), false, false, false)
 
 	Convey("Should be able to generate map from string column", t, func() {
 		So(err, ShouldBeNil)
@@ -147,7 +187,7 @@ type testStruct struct {
 }
 `
 
-	bytes, err = Generate(columnMap, "test_table", "testStruct", "test", false, false, true)
+	bytes, err = generateTestStruct(testTable(columnMap), false, false, true)
 
 	Convey("Should be able to generate map from string column", t, func() {
 		So(err, ShouldBeNil)
@@ -179,7 +219,7 @@ type testStruct struct {
 }
 `
 
-	bytes, err := Generate(columnMap, "test_table", "testStruct", "test", false, false, false)
+	bytes, err := generateTestStruct(testTable(columnMap), false, false, false)
 
 	Convey("Should be able to generate map from string column", t, func() {
 		So(err, ShouldBeNil)
@@ -199,7 +239,7 @@ type testStruct struct {
 }
 `
 
-	bytes, err = Generate(columnMap, "test_table", "testStruct", "test", false, false, true)
+	bytes, err = generateTestStruct(testTable(columnMap), false, false, true)
 
 	Convey("Should be able to generate map from string column", t, func() {
 		So(err, ShouldBeNil)
@@ -238,7 +278,7 @@ type testStruct struct {
 }
 `
 
-	bytes, err := Generate(columnMap, "test_table", "testStruct", "test", false, false, false)
+	bytes, err := generateTestStruct(testTable(columnMap), false, false, false)
 
 	Convey("Should be able to generate map from string column", t, func() {
 		So(err, ShouldBeNil)
@@ -262,7 +302,7 @@ type testStruct struct {
 }
 `
 
-	bytes, err = Generate(columnMap, "test_table", "testStruct", "test", false, false, true)
+	bytes, err = generateTestStruct(testTable(columnMap), false, false, true)
 
 	Convey("Should be able to generate map from string column", t, func() {
 		So(err, ShouldBeNil)
@@ -285,7 +325,7 @@ type testStruct struct {
 }
 `
 
-	bytes, err := Generate(columnMap, "test_table", "testStruct", "test", true, false, false)
+	bytes, err := generateTestStruct(testTable(columnMap), true, false, false)
 
 	Convey("Should be able to generate map from string column", t, func() {
 		So(err, ShouldBeNil)
@@ -301,18 +341,13 @@ type testStruct struct {
 	NullStringColumn sql.NullString ` + "`gorm:\"column:nullStringColumn\"`" + `
 	StringColumn     string         ` + "`gorm:\"column:stringColumn\"`" + `
 }
-
-// TableName sets the insert table name for this struct type
-func (t *testStruct) TableName() string {
-	return "test_table"
-}
 `
 
 	columnMap := map[string]map[string]string{
 		"stringColumn":     {"nullable": "NO", "value": "varchar"},
 		"nullStringColumn": {"nullable": "YES", "value": "varchar"},
 	}
-	bytes, err := Generate(columnMap, "test_table", "testStruct", "test", false, true, false)
+	bytes, err := generateTestStruct(testTable(columnMap), false, true, false)
 
 	Convey("Should be able to generate map from string column", t, func() {
 		So(err, ShouldBeNil)
@@ -332,7 +367,7 @@ type testStruct struct {
 	columnMap := map[string]map[string]string{
 		"1stringColumn": {"nullable": "NO", "value": "varchar"},
 	}
-	bytes, err := Generate(columnMap, "test_table", "testStruct", "test", false, false, false)
+	bytes, err := generateTestStruct(testTable(columnMap), false, false, false)
 
 	Convey("Should be able to generate map from string column", t, func() {
 		So(err, ShouldBeNil)
@@ -352,7 +387,7 @@ type testStruct struct {
 	columnMap := map[string]map[string]string{
 		"string_Column": {"nullable": "NO", "value": "varchar"},
 	}
-	bytes, err := Generate(columnMap, "test_table", "testStruct", "test", false, false, false)
+	bytes, err := generateTestStruct(testTable(columnMap), false, false, false)
 
 	Convey("Should be able to generate map from string column", t, func() {
 		So(err, ShouldBeNil)
@@ -372,7 +407,7 @@ type testStruct struct {
 	columnMap := map[string]map[string]string{
 		"API": {"nullable": "NO", "value": "varchar"},
 	}
-	bytes, err := Generate(columnMap, "test_table", "testStruct", "test", false, false, false)
+	bytes, err := generateTestStruct(testTable(columnMap), false, false, false)
 
 	Convey("Should be able to generate map from string column", t, func() {
 		So(err, ShouldBeNil)
@@ -414,10 +449,810 @@ type testStruct struct {
 		"TimeStamp": {"nullable": "YES", "value": "timestamp"},
 	}
 
-	bytes, err := Generate(columnMap, "test_table", "testStruct", "test", false, false, true)
+	bytes, err := generateTestStruct(testTable(columnMap), false, false, true)
 
 	Convey("Should be able to generate map for guregu types", t, func() {
 		So(err, ShouldBeNil)
 		So(string(bytes), ShouldEqual, expectedStruct)
 	})
+
+	Convey("Should map the common mysql types without type mapping", t, func() {
+		So(mysqlTypeToGoType("json", true, true), ShouldEqual, golangJSON)
+		So(mysqlTypeToGoType("year", true, true), ShouldEqual, gureguNullInt)
+		So(mysqlTypeToGoType("set", false, false), ShouldEqual, "string")
+		So(mysqlTypeToGoType("bit", true, false), ShouldEqual, golangByteArray)
+		So(mysqlTypeToGoType("geometry", false, false), ShouldEqual, "")
+	})
+}
+
+func TestGeneratorIndependentTables(t *testing.T) {
+	g := NewGenerator(Options{GormAnnotation: true, GureguTypes: true})
+	users := &Table{Name: "users", Columns: []*Column{
+		{Name: "id", DataType: "int", Key: "PRI"},
+		{Name: "nickname", DataType: "varchar", Nullable: true},
+	}}
+	logs := &Table{Name: "logs", Columns: []*Column{
+		{Name: "message", DataType: "text"},
+	}}
+
+	var wg sync.WaitGroup
+	states := make([]*generation, 20)
+	for i := range states {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			states[i] = &generation{}
+			if i%2 == 0 {
+				g.generateMysqlTypes(users, states[i])
+			} else {
+				g.generateMysqlTypes(logs, states[i])
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	Convey("Should not leak keys or imports between tables", t, func() {
+		for i, s := range states {
+			if i%2 == 0 {
+				So(s.pk, ShouldEqual, "id")
+				So(s.haveNull, ShouldBeTrue)
+			} else {
+				So(s.pk, ShouldEqual, "")
+				So(s.haveNull, ShouldBeFalse)
+			}
+		}
+	})
+}
+
+func TestCommentGenerate(t *testing.T) {
+	expectedStruct :=
+		`package test
+
+type testStruct struct {
+	// 用户名
+	Name string
+	// order status:
+	// 0 new, 1 paid
+	Status int
+}
+`
+
+	table := &Table{Name: "orders", Columns: []*Column{
+		{Name: "name", DataType: "varchar", Comment: "用户名"},
+		{Name: "status", DataType: "tinyint", Comment: "order status: \r\n 0 new, 1 paid "},
+	}}
+	bytes, err := generateTestStruct(table, false, false, false)
+
+	Convey("Should render column comments as doc comments", t, func() {
+		So(err, ShouldBeNil)
+		So(string(bytes), ShouldEqual, expectedStruct)
+	})
+
+	Convey("Should render a one line table comment", t, func() {
+		So(docComment("Orders 订单表"), ShouldEqual, "// Orders 订单表")
+	})
+}
+
+func TestMysqlColumnTypeGenerate(t *testing.T) {
+	table := &Table{Name: "flags", Columns: []*Column{
+		{Name: "active", DataType: "tinyint", ColumnType: "tinyint(1)"},
+		{Name: "deleted", DataType: "bit", ColumnType: "bit(1)", Nullable: true},
+		{Name: "id", DataType: "bigint", ColumnType: "bigint(20) unsigned"},
+		{Name: "level", DataType: "tinyint", ColumnType: "tinyint(3) unsigned"},
+		{Name: "parent_id", DataType: "bigint", ColumnType: "bigint unsigned", Nullable: true},
+		{Name: "port", DataType: "smallint", ColumnType: "smallint unsigned"},
+		{Name: "score", DataType: "int", ColumnType: "int(10) unsigned", Nullable: true},
+		{Name: "views", DataType: "mediumint", ColumnType: "mediumint unsigned"},
+	}}
+
+	Convey("Should map unsigned integers and tinyint(1) from the column type, bit(1) stays bytes", t, func() {
+		bytes, err := generateColumnTypeStruct(table, Options{UnsignedTypes: true, TinyintAsBool: true})
+		So(err, ShouldBeNil)
+		So(string(bytes), ShouldEqual, `package test
+
+type testStruct struct {
+	Active   bool
+	Deleted  []byte
+	ID       uint64
+	Level    uint8
+	ParentID *uint64
+	Port     uint16
+	Score    sql.NullInt64
+	Views    uint32
+}
+`)
+	})
+
+	Convey("Should use the guregu null types", t, func() {
+		bytes, err := generateColumnTypeStruct(table, Options{UnsignedTypes: true, TinyintAsBool: true, GureguTypes: true})
+		So(err, ShouldBeNil)
+		So(string(bytes), ShouldContainSubstring, "Deleted  []byte")
+		So(string(bytes), ShouldContainSubstring, "Score    null.Int")
+	})
+
+	Convey("Should keep the data type mapping when switched off", t, func() {
+		bytes, err := generateColumnTypeStruct(table, Options{})
+		So(err, ShouldBeNil)
+		So(string(bytes), ShouldContainSubstring, "ID       int64\n")
+		So(string(bytes), ShouldContainSubstring, "Level    int\n")
+		So(string(bytes), ShouldContainSubstring, "ParentID sql.NullInt64\n")
+	})
+}
+
+func generateColumnTypeStruct(table *Table, opts Options) ([]byte, error) {
+	g := NewGenerator(opts)
+	src := fmt.Sprintf("package test\n\ntype testStruct %s}", g.generateMysqlTypes(table, &generation{}))
+	return format.Source([]byte(src))
+}
+
+func TestDefaultGenerate(t *testing.T) {
+	table := &Table{Name: "orders", Columns: []*Column{
+		{Name: "id", DataType: "int", Key: "PRI", Extra: "auto_increment"},
+		{Name: "status", DataType: "varchar", Default: stringPtr("it's new")},
+		{Name: "retries", DataType: "int", Default: stringPtr("3")},
+		{Name: "amount", DataType: "decimal", Nullable: true, Default: stringPtr("-1.50")},
+		{Name: "token", DataType: "char", Default: stringPtr("uuid()"), Extra: "DEFAULT_GENERATED"},
+		{Name: "created_at", DataType: "datetime", Default: stringPtr("CURRENT_TIMESTAMP"), Extra: "DEFAULT_GENERATED"},
+		{Name: "modified", DataType: "timestamp", Default: stringPtr("CURRENT_TIMESTAMP"), Extra: "DEFAULT_GENERATED on update CURRENT_TIMESTAMP"},
+	}}
+	g := NewGenerator(Options{GormAnnotation: true})
+	s := &generation{}
+	bytes, err := format.Source([]byte(fmt.Sprintf("package test\n\ntype Orders %s}\n%s", g.generateMysqlTypes(table, s), s.constructor("Orders"))))
+
+	Convey("Should add default and auto_increment tags and a constructor", t, func() {
+		So(err, ShouldBeNil)
+		So(string(bytes), ShouldEqual, `package test
+
+type Orders struct {
+	ID        int             `+"`"+`gorm:"column:id;primary_key;auto_increment"`+"`"+`
+	Status    string          `+"`"+`gorm:"column:status;default:'it''s new'"`+"`"+`
+	Retries   int             `+"`"+`gorm:"column:retries;default:3"`+"`"+`
+	Amount    sql.NullFloat64 `+"`"+`gorm:"column:amount;default:-1.50"`+"`"+`
+	Token     string          `+"`"+`gorm:"column:token;default:uuid()"`+"`"+`
+	CreatedAt time.Time       `+"`"+`gorm:"column:created_at;default:CURRENT_TIMESTAMP"`+"`"+`
+	Modified  time.Time       `+"`"+`gorm:"column:modified;default:CURRENT_TIMESTAMP"`+"`"+`
+}
+
+// NewOrders returns a new Orders with the column defaults
+func NewOrders() *Orders {
+	return &Orders{
+		Status:    "it's new",
+		Retries:   3,
+		Amount:    sql.NullFloat64{Float64: -1.5, Valid: true},
+		CreatedAt: time.Now(),
+		Modified:  time.Now(),
+	}
+}
+`)
+	})
+
+	Convey("Should detect the updated at column from ON UPDATE", t, func() {
+		So(s.createdAtKey, ShouldEqual, "created_at")
+		So(s.updatedAtKey, ShouldEqual, "modified")
+	})
+
+	Convey("Should read the defaults of boolean columns", t, func() {
+		So(goDefault(&Column{Default: stringPtr("b'1'")}, golangBool), ShouldEqual, "true")
+		So(goDefault(&Column{Default: stringPtr("0")}, gureguNullBool), ShouldEqual, "null.BoolFrom(false)")
+		So(goDefault(&Column{Default: stringPtr("abc")}, golangInt), ShouldEqual, "")
+	})
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestIndexGenerate(t *testing.T) {
+	table := &Table{Name: "posts", Columns: []*Column{
+		{Name: "id", DataType: "int", Key: "PRI"},
+		{Name: "user_id", DataType: "int", Key: "MUL"},
+		{Name: "slug", DataType: "varchar"},
+		{Name: "title", DataType: "varchar"},
+		{Name: "body", DataType: "text"},
+	}, Indexes: []*Index{
+		{Name: "PRIMARY", Primary: true, Unique: true, Columns: []string{"id"}},
+		{Name: "ft_body", Kind: "FULLTEXT", Columns: []string{"body"}},
+		{Name: "idx_title", Columns: []string{"title"}, Lengths: []int{32}},
+		{Name: "idx_title_user", Columns: []string{"title", "user_id"}},
+		{Name: "idx_user_title", Columns: []string{"user_id", "title"}, Lengths: []int{0, 0}},
+		{Name: "uk_user_slug", Unique: true, Kind: "UNIQUE", Columns: []string{"user_id", "slug"}},
+	}}
+	bytes, err := generateTestStruct(table, false, true, false)
+
+	Convey("Should add one index and one unique_index tag listing the indexes of each column", t, func() {
+		So(err, ShouldBeNil)
+		So(string(bytes), ShouldEqual, `package test
+
+type testStruct struct {
+	ID     int    `+"`"+`gorm:"column:id;primary_key"`+"`"+`
+	UserID int    `+"`"+`gorm:"column:user_id;index:idx_user_title;unique_index:uk_user_slug"`+"`"+`
+	Slug   string `+"`"+`gorm:"column:slug;unique_index:uk_user_slug"`+"`"+`
+	Title  string `+"`"+`gorm:"column:title;index:idx_user_title"`+"`"+`
+	Body   string `+"`"+`gorm:"column:body"`+"`"+`
+}
+`)
+	})
+
+	Convey("Should report the indexes gorm would recreate differently instead of tagging them", t, func() {
+		So(NewGenerator(Options{GormAnnotation: true}).UntaggedIndexes([]*Table{table}), ShouldResemble, []UntaggedIndex{
+			{Table: "posts", Index: "ft_body", Reason: "fulltext index"},
+			{Table: "posts", Index: "idx_title", Reason: "prefix length"},
+			{Table: "posts", Index: "idx_title_user", Reason: "columns title, user_id not in table order"},
+		})
+		So(NewGenerator(Options{}).UntaggedIndexes([]*Table{table}), ShouldBeNil)
+	})
+}
+
+func TestUniqueKeyRepositoryGenerate(t *testing.T) {
+	table := &Table{Name: "users", Columns: []*Column{
+		{Name: "id", DataType: "int", Key: "PRI"},
+		{Name: "email", DataType: "varchar", Key: "UNI"},
+		{Name: "tenant_id", DataType: "int", Key: "MUL"},
+		{Name: "type", DataType: "varchar"},
+		{Name: "created_at", DataType: "datetime"},
+		{Name: "updated_at", DataType: "datetime"},
+	}, Indexes: []*Index{
+		{Name: "PRIMARY", Primary: true, Unique: true, Columns: []string{"id"}},
+		{Name: "uk_email", Unique: true, Kind: "UNIQUE", Columns: []string{"email"}},
+		{Name: "uk_email_2", Unique: true, Kind: "UNIQUE", Columns: []string{"email"}},
+		{Name: "idx_type", Columns: []string{"type"}},
+		{Name: "uk_tenant_type", Unique: true, Kind: "UNIQUE", Columns: []string{"tenant_id", "type"}},
+	}}
+	g := NewGenerator(Options{GormAnnotation: true})
+	s := &generation{}
+	g.generateMysqlTypes(table, s)
+
+	Convey("Should collect the unique keys once", t, func() {
+		So(len(s.uniqueKeys), ShouldEqual, 2)
+		So(s.uniqueKeys[0].Name, ShouldEqual, "Email")
+		So(s.uniqueKeys[1].Name, ShouldEqual, "TenantIDAndType")
+		So(s.uniqueKeys[1].Fields[1], ShouldResemble, tplField{Column: "type", Field: "Type", Arg: "typeValue", Type: "string"})
+	})
+
+	Convey("Should declare the finder methods in the interface", t, func() {
+		src := rendered(g.repoInterfaceTpl("Users", "users", s))
+		So(src, ShouldContainSubstring, "FetchByEmail(email string, fields string) (*model.Users, error)")
+		So(src, ShouldContainSubstring, "ExistsByTenantIDAndType(tenantID int, typeValue string, ) (bool, error)")
+		So(src, ShouldContainSubstring, "UpdateByTenantIDAndType(tenantID int, typeValue string, set map[string]interface{}) error")
+		So(src, ShouldContainSubstring, "DeleteByEmail(email string, ) error")
+	})
+
+	Convey("Should implement the finder methods", t, func() {
+		src, err := format.Source([]byte("package mysql\n" + rendered(g.repoTpl("Users", "users", s))))
+		So(err, ShouldBeNil)
+		So(string(src), ShouldContainSubstring, `func (a *users) DeleteByTenantIDAndType(tenantID int, typeValue string) error {
+	if err := a.db.Where(map[string]interface{}{"tenant_id": tenantID, "type": typeValue}).Delete(model.Users{}).Error; err != nil {`)
+		So(string(src), ShouldContainSubstring, `err := a.db.Select(fields).Where(map[string]interface{}{"email": email}).First(&ret).Error`)
+	})
+}
+
+func TestAssociationGenerate(t *testing.T) {
+	table := &Table{Name: "orders", Columns: []*Column{
+		{Name: "id", DataType: "int", Key: "PRI"},
+		{Name: "buyer_id", DataType: "int", Key: "MUL"},
+		{Name: "parent", DataType: "int", Key: "MUL"},
+		{Name: "created_at", DataType: "datetime"},
+		{Name: "updated_at", DataType: "datetime"},
+	}, ForeignKeys: []*ForeignKey{
+		{Table: "orders", Columns: []string{"buyer_id"}, RefTable: "users", RefColumns: []string{"id"}},
+		{Table: "orders", Columns: []string{"parent"}, RefTable: "orders", RefColumns: []string{"id"}},
+	}, ReferencedBy: []*ForeignKey{
+		{Table: "orders", Columns: []string{"parent"}, RefTable: "orders", RefColumns: []string{"id"}},
+		{Table: "refunds", Columns: []string{"order_id"}, RefTable: "orders", RefColumns: []string{"id"}},
+		{Table: "refunds", Columns: []string{"replacement_id"}, RefTable: "orders", RefColumns: []string{"id"}},
+	}}
+	g := NewGenerator(Options{GormAnnotation: true, JSONAnnotation: true})
+	s := &generation{}
+	structure := g.generateMysqlTypes(table, s)
+
+	Convey("Should add belongs to and has many fields", t, func() {
+		So(structure, ShouldContainSubstring, "\nBuyer *Users `gorm:\"foreignkey:BuyerID;association_foreignkey:ID\" json:\"buyer,omitempty\"`")
+		So(structure, ShouldContainSubstring, "\nOrders *Orders `gorm:\"foreignkey:Parent;association_foreignkey:ID\" json:\"orders,omitempty\"`")
+		So(structure, ShouldContainSubstring, "\nOrdersByParent []*Orders `gorm:\"foreignkey:Parent;association_foreignkey:ID\" json:\"orders_by_parent,omitempty\"`")
+		So(structure, ShouldContainSubstring, "\nRefundsByOrder []*Refunds `gorm:\"foreignkey:OrderID;association_foreignkey:ID\" json:\"refunds_by_order,omitempty\"`")
+		So(structure, ShouldContainSubstring, "\nRefundsByReplacement []*Refunds `gorm:\"foreignkey:ReplacementID;association_foreignkey:ID\"")
+	})
+
+	Convey("Should add a preloading finder per association", t, func() {
+		So(s.associations, ShouldResemble, []tplAssociation{{Field: "Buyer"}, {Field: "Orders"}, {Field: "OrdersByParent"}, {Field: "RefundsByOrder"}, {Field: "RefundsByReplacement"}})
+		So(rendered(g.repoInterfaceTpl("Orders", "orders", s)), ShouldContainSubstring, "FetchOneByIdWithBuyer(id int, fields string) (*model.Orders, error)")
+		src, err := format.Source([]byte("package mysql\n" + rendered(g.repoTpl("Orders", "orders", s))))
+		So(err, ShouldBeNil)
+		So(string(src), ShouldContainSubstring, `err := a.db.Select(fields).Preload("RefundsByOrder").Where("id = ?", id).First(&ret).Error`)
+	})
+
+	Convey("Should not add associations without gorm annotations", t, func() {
+		structure := NewGenerator(Options{}).generateMysqlTypes(table, &generation{})
+		So(structure, ShouldNotContainSubstring, "*Users")
+	})
+}
+
+func TestManyToManyGenerate(t *testing.T) {
+	schema := newDDLSchema()
+	_ = schema.exec(joinTablesDDL + "ALTER TABLE users ADD created_at DATETIME, ADD updated_at DATETIME;")
+	tables, _ := schema.lookup([]string{"users", "roles", "user_roles"}, "in the test ddl")
+	linkManyToMany(tables)
+	g := NewGenerator(Options{GormAnnotation: true, JSONAnnotation: true})
+	s := &generation{}
+	structure := g.generateMysqlTypes(tables[0], s)
+
+	Convey("Should replace the has many field of the join table by a many2many field", t, func() {
+		So(structure, ShouldNotContainSubstring, "UserRoles")
+		So(structure, ShouldContainSubstring, "\nRoles []*Roles `gorm:\"many2many:user_roles;foreignkey:ID;association_foreignkey:ID;jointable_foreignkey:user_id;association_jointable_foreignkey:role_id\" json:\"roles,omitempty\"`")
+		So(g.generateMysqlTypes(tables[1], &generation{}), ShouldContainSubstring, "\nUsers []*Users `gorm:\"many2many:user_roles;foreignkey:ID;association_foreignkey:ID;jointable_foreignkey:role_id;association_jointable_foreignkey:user_id\"")
+	})
+
+	Convey("Should add the association helpers to the owning repository", t, func() {
+		So(s.manyToMany, ShouldResemble, []tplAssociation{{Field: "Roles", Type: "Roles", Arg: "roles"}})
+		So(rendered(g.repoInterfaceTpl("Users", "users", s)), ShouldContainSubstring, "ReplaceRoles(id int, roles ...*model.Roles) error")
+		src, err := format.Source([]byte("package mysql\n" + rendered(g.repoTpl("Users", "users", s))))
+		So(err, ShouldBeNil)
+		So(string(src), ShouldContainSubstring, `return a.db.Model(&model.Users{ID: id}).Association("Roles").Append(roles).Error`)
+		So(string(src), ShouldContainSubstring, "func (a *users) ListRoles(id int) ([]*model.Roles, error) {")
+	})
+
+	Convey("Should render the join table without repository nor time columns", t, func() {
+		for _, split := range []bool{false, true} {
+			// users has time columns, user_roles only created_at
+			files, errs := g.RenderAll([]*Table{tables[0], tables[2]}, split, 2)
+			So(errs, ShouldResemble, []error{nil, nil})
+			So(len(files[1]), ShouldEqual, 1)
+			So(string(files[1][0].Contents), ShouldContainSubstring, "type UserRoles struct {")
+			So(string(files[1][0].Contents), ShouldNotContainSubstring, "TableName")
+		}
+	})
+
+	Convey("Should name the columns of the join table", t, func() {
+		schema := newDDLSchema()
+		So(schema.exec(`CREATE TABLE accounts (uid INT PRIMARY KEY);
+CREATE TABLE teams (code CHAR(8) PRIMARY KEY);
+CREATE TABLE members (account_ref INT NOT NULL, team CHAR(8) NOT NULL,
+  PRIMARY KEY (account_ref, team), FOREIGN KEY (account_ref) REFERENCES accounts (uid), FOREIGN KEY (team) REFERENCES teams (code));`), ShouldBeNil)
+		tables, _ := schema.lookup([]string{"accounts", "teams", "members"}, "in the test ddl")
+		linkManyToMany(tables)
+		So(g.generateMysqlTypes(tables[0], &generation{}), ShouldContainSubstring,
+			"\nTeams []*Teams `gorm:\"many2many:members;foreignkey:UID;association_foreignkey:Code;jointable_foreignkey:account_ref;association_jointable_foreignkey:team\"")
+	})
+}
+
+func TestCompositeKeyGenerate(t *testing.T) {
+	table := &Table{Name: "order_items", Columns: []*Column{
+		{Name: "order_id", DataType: "int", Key: "PRI"},
+		{Name: "line_no", DataType: "smallint", Key: "PRI"},
+		{Name: "sku", DataType: "varchar"},
+		{Name: "created_at", DataType: "datetime"},
+		{Name: "updated_at", DataType: "datetime"},
+	}}
+	g := NewGenerator(Options{GormAnnotation: true})
+	s := &generation{}
+	g.generateMysqlTypes(table, s)
+
+	Convey("Should keep every key column", t, func() {
+		So(s.pk, ShouldEqual, "")
+		So(len(s.primaryKey), ShouldEqual, 2)
+		So(s.keyType("OrderItems"), ShouldContainSubstring, "type OrderItemsKey struct {\nOrderID int\nLineNo int\n}")
+		So(s.keyType("OrderItems"), ShouldContainSubstring, "func (m *OrderItems) Key() OrderItemsKey {")
+		So(s.generateImport(true), ShouldNotContainSubstring, "errors")
+	})
+
+	Convey("Should rename the key method when a field is named Key", t, func() {
+		keyed := &Table{Name: "settings", Columns: []*Column{
+			{Name: "scope", DataType: "varchar", Key: "PRI"},
+			{Name: "key", DataType: "varchar", Key: "PRI"},
+			{Name: "created_at", DataType: "datetime"},
+			{Name: "updated_at", DataType: "datetime"},
+		}}
+		files, err := g.Render(keyed, "", false)
+		So(err, ShouldBeNil)
+		So(string(files[0].Contents), ShouldContainSubstring, "func (m *Settings) PrimaryKey() SettingsKey {")
+		So(string(files[0].Contents), ShouldNotContainSubstring, ") Key() SettingsKey")
+		files, err = g.Render(keyed, "", true)
+		So(err, ShouldBeNil)
+		So(string(files[2].Contents), ShouldContainSubstring, "return data.PrimaryKey(), nil")
+
+		s := &generation{}
+		g.generateMysqlTypes(keyed, s)
+		s.associations = append(s.associations, tplAssociation{Field: "PrimaryKey"})
+		So(s.keyType("Settings"), ShouldContainSubstring, "func (m *Settings) RowKey() SettingsKey {")
+	})
+
+	Convey("Should replace the by id methods by key methods", t, func() {
+		iface := rendered(g.repoInterfaceTpl("OrderItems", "order_items", s))
+		So(iface, ShouldContainSubstring, "Create(data *model.OrderItems) (model.OrderItemsKey, error)")
+		So(iface, ShouldContainSubstring, "FetchOneByKey(key model.OrderItemsKey, fields string) (*model.OrderItems, error)")
+		So(iface, ShouldNotContainSubstring, "ById")
+		So(iface, ShouldNotContainSubstring, "FetchByIds")
+
+		src, err := format.Source([]byte("package mysql\n" + rendered(g.repoTpl("OrderItems", "order_items", s))))
+		So(err, ShouldBeNil)
+		So(string(src), ShouldContainSubstring, `Where(map[string]interface{}{"order_id": key.OrderID, "line_no": key.LineNo}).Delete(model.OrderItems{})`)
+		So(string(src), ShouldNotContainSubstring, "ById")
+	})
+}
+
+func TestPrimaryKeyTypeGenerate(t *testing.T) {
+	table := &Table{Name: "sessions", Columns: []*Column{
+		{Name: "token", DataType: "binary", ColumnType: "binary(16)", Key: "PRI"},
+		{Name: "created_at", DataType: "datetime"},
+		{Name: "updated_at", DataType: "datetime"},
+	}}
+	g := NewGenerator(Options{GormAnnotation: true})
+	s := &generation{}
+	g.generateMysqlTypes(table, s)
+
+	Convey("Should use the go type of the key column in the signatures", t, func() {
+		So(s.pkType(), ShouldEqual, "[]byte")
+		iface := rendered(g.repoInterfaceTpl("Sessions", "sessions", s))
+		So(iface, ShouldContainSubstring, "Create(data *model.Sessions) ([]byte, error)")
+		So(iface, ShouldContainSubstring, "FetchOneById(id []byte, fields string) (*model.Sessions, error)")
+		So(iface, ShouldContainSubstring, "FetchByIds(ids [][]byte, fields string) ([]*model.Sessions, error)")
+		So(iface, ShouldNotContainSubstring, " int,")
+	})
+
+	Convey("Should filter on the key column whatever its type", t, func() {
+		src, err := format.Source([]byte("package mysql\n" + rendered(g.repoTpl("Sessions", "sessions", s))))
+		So(err, ShouldBeNil)
+		So(string(src), ShouldContainSubstring, "func (a *sessions) Create(data *model.Sessions) (id []byte, err error) {")
+		So(string(src), ShouldContainSubstring, `err := a.db.Select(fields).Where("token = ?", id).First(&ret).Error`)
+		So(string(src), ShouldContainSubstring, `err := a.db.Select(fields).Where("token IN (?)", ids).Find(&ret).Error`)
+	})
+}
+
+func TestKeylessRepositoryGenerate(t *testing.T) {
+	table := &Table{Name: "audit_log", Columns: []*Column{
+		{Name: "actor", DataType: "varchar"},
+		{Name: "created_at", DataType: "datetime"},
+		{Name: "updated_at", DataType: "datetime"},
+	}}
+	g := NewGenerator(Options{GormAnnotation: true})
+	s := &generation{}
+	g.generateMysqlTypes(table, s)
+
+	Convey("Should only declare the insert and where based methods", t, func() {
+		iface := rendered(g.repoInterfaceTpl("AuditLog", "audit_log", s))
+		So(iface, ShouldContainSubstring, "// AuditLogRepository has no by id methods, audit_log has no primary key")
+		So(iface, ShouldContainSubstring, "Create(data *model.AuditLog) error")
+		So(iface, ShouldContainSubstring, "DeleteByWhere(where map[string]interface{}) error")
+		So(iface, ShouldNotContainSubstring, "ById")
+		So(iface, ShouldNotContainSubstring, "Update")
+	})
+
+	Convey("Should implement them without the primary key", t, func() {
+		So(s.generateImport(true), ShouldNotContainSubstring, "errors")
+		src, err := format.Source([]byte("package mysql\n" + rendered(g.repoTpl("AuditLog", "audit_log", s))))
+		So(err, ShouldBeNil)
+		So(string(src), ShouldContainSubstring, "func (a *auditLog) Create(data *model.AuditLog) error {")
+		So(string(src), ShouldNotContainSubstring, "data.,")
+		So(string(src), ShouldNotContainSubstring, "ById")
+	})
+
+	Convey("Should not set the time columns a keyless table does not have", t, func() {
+		events := &Table{Name: "events", Columns: []*Column{
+			{Name: "code", DataType: "varchar"},
+			{Name: "payload", DataType: "text"},
+		}, Indexes: []*Index{{Name: "uk_code", Unique: true, Columns: []string{"code"}}}}
+		files, err := g.Render(events, "", true)
+		So(err, ShouldBeNil)
+		So(string(files[2].Contents), ShouldContainSubstring, "func (a *events) Create(data *model.Events) error {\n\treturn a.db.Create(data).Error\n}")
+		So(string(files[2].Contents), ShouldNotContainSubstring, "time")
+		So(string(files[2].Contents), ShouldNotContainSubstring, `set[""]`)
+
+		events.Columns = append(events.Columns, &Column{Name: "created_at", DataType: "datetime"})
+		files, err = g.Render(events, "", true)
+		So(err, ShouldBeNil)
+		So(string(files[2].Contents), ShouldContainSubstring, "\t\"time\"\n")
+		So(string(files[2].Contents), ShouldContainSubstring, "data.CreatedAt = time.Now()\n\treturn a.db.Create(data).Error")
+	})
+}
+
+func TestViewRepositoryGenerate(t *testing.T) {
+	table := &Table{Name: "order_totals", View: true, Columns: []*Column{
+		{Name: "user_id", DataType: "int"},
+		{Name: "total", DataType: "decimal"},
+		{Name: "last_order_at", DataType: "datetime"},
+	}}
+	g := NewGenerator(Options{GormAnnotation: true})
+	s := &generation{}
+	structure := g.generateMysqlTypes(table, s)
+
+	Convey("Should tag the fields like the ones of a table", t, func() {
+		So(structure, ShouldContainSubstring, "UserID int `gorm:\"column:user_id\"`")
+		So(structure, ShouldContainSubstring, "LastOrderAt time.Time `gorm:\"column:last_order_at\"`")
+	})
+
+	Convey("Should only declare the read methods", t, func() {
+		iface := rendered(g.repoInterfaceTpl("OrderTotals", "order_totals", s))
+		So(iface, ShouldContainSubstring, "// OrderTotalsRepository reads the order_totals view")
+		So(iface, ShouldContainSubstring, "FetchByWhere(where map[string]interface{}, fields string) ([]*model.OrderTotals, error)")
+		So(iface, ShouldContainSubstring, "Paginate(where map[string]interface{}, fields string, page, size int) ([]*model.OrderTotals, error)")
+		for _, method := range []string{"Create", "Update", "Delete", "ById"} {
+			So(iface, ShouldNotContainSubstring, method)
+		}
+	})
+
+	Convey("Should refuse the writes of the model with gorm hooks", t, func() {
+		files, err := g.Render(table, "", true)
+		So(err, ShouldBeNil)
+		model := string(files[0].Contents)
+		So(model, ShouldContainSubstring, "\"errors\"")
+		So(model, ShouldContainSubstring, "// BeforeSave refuses to write to the order_totals view\nfunc (m *OrderTotals) BeforeSave() error {\n\treturn errors.New(\"order_totals is a read-only view\")\n}")
+		So(model, ShouldContainSubstring, "func (m *OrderTotals) BeforeDelete() error {")
+
+		files, err = NewGenerator(Options{}).Render(table, "", true)
+		So(err, ShouldBeNil)
+		So(string(files[0].Contents), ShouldNotContainSubstring, "BeforeSave")
+	})
+
+	Convey("Should implement them without time", t, func() {
+		So(s.generateImport(true), ShouldNotContainSubstring, "time")
+		src, err := format.Source([]byte("package mysql\n" + rendered(g.repoTpl("OrderTotals", "order_totals", s))))
+		So(err, ShouldBeNil)
+		So(string(src), ShouldContainSubstring, "if err := q.Offset((page - 1) * size).Limit(size).Find(&ret).Error; err != nil {")
+		So(string(src), ShouldNotContainSubstring, "time.Now()")
+		So(string(src), ShouldNotContainSubstring, "Delete(")
+	})
+}
+
+func TestTableOptionsGenerate(t *testing.T) {
+	users := &Table{Name: "users", Columns: []*Column{
+		{Name: "id", DataType: "int", Key: "PRI"},
+		{Name: "registered_at", DataType: "datetime"},
+		{Name: "modified_at", DataType: "datetime"},
+	}}
+	orders := &Table{Name: "orders", Columns: []*Column{
+		{Name: "id", DataType: "int", Key: "PRI"},
+		{Name: "user_id", DataType: "int", Key: "MUL"},
+		{Name: "created_at", DataType: "datetime"},
+		{Name: "updated_at", DataType: "datetime"},
+	}, ForeignKeys: []*ForeignKey{
+		{Table: "orders", Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}},
+	}}
+	output, err := ioutil.TempDir("", "db2struct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(output)
+	g := NewGenerator(Options{GormAnnotation: true, Output: output, Tables: map[string]TableOptions{
+		"users": {StructName: "Member", CreatedAtKey: "registered_at", UpdatedAtKey: "modified_at"},
+	}})
+
+	Convey("Should use the struct name and time columns of the overrides", t, func() {
+		s := &generation{}
+		g.generateMysqlTypes(users, s)
+		src := rendered(g.repoTpl(g.structName("users"), "users", s))
+		So(src, ShouldContainSubstring, "func NewMemberRepository(db *gorm.DB) repository.MemberRepository {")
+		So(src, ShouldContainSubstring, "data.RegisteredAt = time.Now()")
+		So(src, ShouldContainSubstring, `set["modified_at"] = time.Now()`)
+	})
+
+	Convey("Should refer to the overridden struct from the associations", t, func() {
+		structure := g.generateMysqlTypes(orders, &generation{})
+		So(structure, ShouldContainSubstring, "\nUser *Member `gorm:\"foreignkey:UserID;association_foreignkey:ID\"`")
+	})
+
+	Convey("Should write the files to the output directory", t, func() {
+		So(g.GenerateAll([]*Table{users, orders}, true, 2), ShouldResemble, []error{nil, nil})
+		model, err := ioutil.ReadFile(filepath.Join(output, "model", "users_model.go"))
+		So(err, ShouldBeNil)
+		So(string(model), ShouldContainSubstring, "type Member struct {")
+		for _, path := range []string{"model/orders_model.go", "repository/users_repository.go", "repository/mysql/orders_repository.go"} {
+			_, err := os.Stat(filepath.Join(output, path))
+			So(err, ShouldBeNil)
+		}
+	})
+}
+
+func TestTypeMapGenerate(t *testing.T) {
+	table := &Table{Name: "orders", Columns: []*Column{
+		{Name: "id", DataType: "int", Key: "PRI"},
+		{Name: "code", DataType: "char", ColumnType: "char(36)"},
+		{Name: "amount", DataType: "decimal", ColumnType: "decimal(10,2)"},
+		{Name: "meta", DataType: "json", Nullable: true},
+		{Name: "created_at", DataType: "datetime"},
+		{Name: "updated_at", DataType: "datetime"},
+	}, Indexes: []*Index{{Name: "uk_code", Unique: true, Columns: []string{"code"}}}}
+	g := NewGenerator(Options{GormAnnotation: true, Types: TypeMap{
+		"DECIMAL":      "github.com/shopspring/decimal.Decimal",
+		"orders.meta":  "json.RawMessage",
+		"orders.code":  "github.com/google/uuid.UUID",
+		"users.amount": "int",
+	}})
+	s := &generation{}
+	structure := g.generateMysqlTypes(table, s)
+
+	Convey("Should use the mapped types", t, func() {
+		So(structure, ShouldContainSubstring, "\nCode uuid.UUID `gorm:\"column:code;unique_index:uk_code\"`")
+		So(structure, ShouldContainSubstring, "\nAmount decimal.Decimal `gorm:\"column:amount\"`")
+		So(structure, ShouldContainSubstring, "\nMeta json.RawMessage `gorm:\"column:meta\"`")
+	})
+
+	Convey("Should import their packages", t, func() {
+		src, err := format.Source([]byte("package model\n" + s.generateAllImport() + "type Orders " + structure + "}"))
+		So(err, ShouldBeNil)
+		So(string(src), ShouldContainSubstring, "\t\"encoding/json\"\n")
+		So(string(src), ShouldContainSubstring, "\t\"github.com/google/uuid\"\n")
+		So(string(src), ShouldContainSubstring, "\t\"github.com/shopspring/decimal\"\n")
+		So(strings.Count(string(src), "encoding/json"), ShouldEqual, 1)
+	})
+
+	Convey("Should only import the key types in the repository", t, func() {
+		imports := s.generateImport(true)
+		So(imports, ShouldContainSubstring, "\"github.com/google/uuid\"")
+		So(imports, ShouldNotContainSubstring, "decimal")
+		So(rendered(g.repoInterfaceTpl("Orders", "orders", s)), ShouldContainSubstring, "FetchByCode(code uuid.UUID, fields string) (*model.Orders, error)")
+	})
+}
+
+func TestUnmappedGenerate(t *testing.T) {
+	table := &Table{Name: "places", Columns: []*Column{
+		{Name: "id", DataType: "int", Key: "PRI"},
+		{Name: "location", DataType: "geometry", ColumnType: "geometry"},
+		{Name: "area", DataType: "polygon", ColumnType: "polygon", Nullable: true},
+	}}
+
+	Convey("Should list the unmapped columns in strict mode", t, func() {
+		g := NewGenerator(Options{Types: TypeMap{"places.area": "[]byte"}})
+		So(g.Unmapped([]*Table{table}), ShouldResemble, []UnmappedColumn{{Table: "places", Column: "location", Type: "geometry"}})
+
+		g = NewGenerator(Options{})
+		_, err := g.GenerateOne(table, "")
+		So(err.(*GenerateError).Err, ShouldResemble, &UnmappedError{Columns: []UnmappedColumn{
+			{Table: "places", Column: "location", Type: "geometry"},
+			{Table: "places", Column: "area", Type: "polygon"},
+		}})
+		So(err.Error(), ShouldEqual, "places: no go type for places.location (geometry), places.area (polygon), map them with --type or set a fallback type")
+		_, err = g.Generate(table, "")
+		So(err.(*GenerateError).Err, ShouldHaveSameTypeAs, &UnmappedError{})
+	})
+
+	Convey("Should use the fallback type in lenient mode", t, func() {
+		g := NewGenerator(Options{FallbackType: "json.RawMessage"})
+		s := &generation{}
+		structure := g.generateMysqlTypes(table, s)
+		So(structure, ShouldContainSubstring, "\nLocation json.RawMessage")
+		So(structure, ShouldContainSubstring, "\nArea json.RawMessage")
+		So(s.generateAllImport(), ShouldContainSubstring, "\"encoding/json\"")
+		_, err := g.GenerateOne(table, "")
+		So(err, ShouldBeNil)
+	})
+}
+
+func TestRender(t *testing.T) {
+	table := &Table{Name: "users", Columns: []*Column{
+		{Name: "id", DataType: "int", Key: "PRI"},
+		{Name: "name", DataType: "varchar"},
+		{Name: "created_at", DataType: "datetime"},
+		{Name: "updated_at", DataType: "datetime"},
+	}}
+	paths := func(files []File) []string {
+		var paths []string
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+		return paths
+	}
+
+	Convey("Should return the files instead of writing them", t, func() {
+		g := NewGenerator(Options{GormAnnotation: true})
+		files, err := g.Render(table, "", true)
+		So(err, ShouldBeNil)
+		So(paths(files), ShouldResemble, []string{"model/users_model.go", "repository/users_repository.go", "repository/mysql/users_repository.go"})
+		So(string(files[0].Contents), ShouldStartWith, "package model\n")
+		So(string(files[2].Contents), ShouldContainSubstring, "func NewUsersRepository(db *gorm.DB) repository.UsersRepository {")
+
+		files, err = g.Render(table, "Account", false)
+		So(err, ShouldBeNil)
+		So(paths(files), ShouldResemble, []string{"users.go"})
+		So(string(files[0].Contents), ShouldContainSubstring, "type Account struct {")
+
+		files, err = NewGenerator(Options{}).Render(table, "", true)
+		So(err, ShouldBeNil)
+		So(paths(files), ShouldResemble, []string{"model/users_model.go"})
+	})
+
+	Convey("Should return structured errors", t, func() {
+		timeless := &Table{Name: "audit_log", Columns: []*Column{{Name: "id", DataType: "int", Key: "PRI"}, {Name: "message", DataType: "text"}}}
+		_, err := NewGenerator(Options{GormAnnotation: true}).Render(timeless, "", true)
+		So(err, ShouldResemble, &GenerateError{Table: "audit_log", Err: &TimeColumnsError{CreatedAt: true, UpdatedAt: true}})
+		So(err.Error(), ShouldEqual, "audit_log: no created and updated time column found, set them with --create_at/--update_at or in the overrides")
+
+		_, err = NewGenerator(Options{}).Render(table, "Bad Name", false)
+		formatErr, ok := err.(*GenerateError).Err.(*FormatError)
+		So(ok, ShouldBeTrue)
+		So(formatErr.Path, ShouldEqual, "users.go")
+		So(formatErr.Source, ShouldContainSubstring, "type Bad Name struct {")
+	})
+
+	Convey("Should render and write many tables", t, func() {
+		output, err := ioutil.TempDir("", "db2struct")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(output)
+
+		g := NewGenerator(Options{Output: output})
+		files, errs := g.RenderAll([]*Table{table, {Name: "places", Columns: []*Column{{Name: "location", DataType: "geometry"}}}}, false, 2)
+		So(paths(files[0]), ShouldResemble, []string{"users.go"})
+		So(files[1], ShouldBeNil)
+		So(errs[0], ShouldBeNil)
+		So(errs[1].Error(), ShouldStartWith, "places: no go type for places.location (geometry)")
+
+		So(g.WriteFiles([]File{{Path: "model/users_model.go", Contents: files[0][0].Contents}}), ShouldBeNil)
+		written, err := ioutil.ReadFile(filepath.Join(output, "model", "users_model.go"))
+		So(err, ShouldBeNil)
+		So(written, ShouldResemble, files[0][0].Contents)
+	})
+}
+
+func TestGenerateErrors(t *testing.T) {
+	Convey("Should return the template errors", t, func() {
+		_, err := execTpl("model", "{{if}}", tplParams{})
+		So(err.Error(), ShouldStartWith, "parsing the model template: ")
+		_, err = execTpl("repository", "{{.Missing}}", tplParams{})
+		So(err.Error(), ShouldStartWith, "rendering the repository template: ")
+	})
+
+	Convey("Should name the column of an invalid mapping", t, func() {
+		table := &Table{Name: "places", Columns: []*Column{
+			{Name: "id", DataType: "int", Key: "PRI"},
+			{Name: "location", DataType: "geometry"},
+		}}
+		_, err := NewGenerator(Options{Types: TypeMap{"geometry": "geo.Point"}}).Render(table, "", false)
+		So(err.Error(), ShouldEqual, "places: column places.location: unknown package geo in geo.Point, write its import path, e.g. github.com/shopspring/decimal.Decimal")
+		_, err = NewGenerator(Options{FallbackType: "geo.Point"}).Render(table, "", false)
+		So(err.Error(), ShouldStartWith, "places: column places.location: unknown package geo")
+	})
+}
+
+func TestRepositoryImports(t *testing.T) {
+	table := &Table{Name: "orders", Columns: []*Column{
+		{Name: "id", DataType: "int", Key: "PRI"},
+		{Name: "code", DataType: "varchar", Nullable: true},
+		{Name: "meta", DataType: "json"},
+		{Name: "note", DataType: "text", Nullable: true},
+		{Name: "created_at", DataType: "datetime"},
+		{Name: "updated_at", DataType: "datetime"},
+	}, Indexes: []*Index{{Name: "uk_code", Unique: true, Columns: []string{"code"}}}}
+	g := NewGenerator(Options{GormAnnotation: true, GureguTypes: true, Types: TypeMap{"json": "json.RawMessage"}})
+	files, err := g.Render(table, "", true)
+
+	Convey("Should only import the packages of the key types in the repository", t, func() {
+		So(err, ShouldBeNil)
+		So(string(files[0].Contents), ShouldContainSubstring, "\"encoding/json\"")
+		repository := string(files[2].Contents)
+		So(repository, ShouldNotContainSubstring, "encoding/json")
+		So(strings.Count(repository, "\"gopkg.in/guregu/null.v3\""), ShouldEqual, 1)
+		So(repository, ShouldContainSubstring, "FetchByCode(code null.String, fields string)")
+	})
+
+	Convey("Should not import the null types of other columns", t, func() {
+		table := &Table{Name: "notes", Columns: table.Columns[:5:5]}
+		table.Columns = append(table.Columns, &Column{Name: "updated_at", DataType: "datetime"})
+		files, err := g.Render(table, "", true)
+		So(err, ShouldBeNil)
+		So(string(files[2].Contents), ShouldNotContainSubstring, "null")
+	})
+}
+
+func TestRepositoryImportGroups(t *testing.T) {
+	table := &Table{Name: "orders", Columns: []*Column{
+		{Name: "id", DataType: "int", Key: "PRI"},
+		{Name: "code", DataType: "varchar", Nullable: true},
+		{Name: "created_at", DataType: "datetime"},
+		{Name: "updated_at", DataType: "datetime"},
+	}, Indexes: []*Index{{Name: "uk_code", Unique: true, Columns: []string{"code"}}}}
+	files, err := NewGenerator(Options{GormAnnotation: true}).Render(table, "", true)
+
+	Convey("Should import the standard library before the other packages", t, func() {
+		So(err, ShouldBeNil)
+		So(string(files[2].Contents), ShouldContainSubstring, "import (\n\t\"database/sql\"\n\t\"errors\"\n\t\"time\"\n\n\t\"github.com/jinzhu/gorm\"\n)")
+	})
+
+	Convey("Should import the key types in the repository interface", t, func() {
+		iface := string(files[1].Contents)
+		So(iface, ShouldStartWith, "package repository\n\nimport (\n\t\"database/sql\"\n)\n")
+		So(iface, ShouldContainSubstring, "FetchByCode(code sql.NullString, fields string) (*model.Orders, error)")
+
+		files, err := NewGenerator(Options{GormAnnotation: true}).Render(&Table{Name: "users", Columns: []*Column{table.Columns[0], table.Columns[2], table.Columns[3]}}, "", true)
+		So(err, ShouldBeNil)
+		So(string(files[1].Contents), ShouldNotContainSubstring, "import")
+	})
 }